@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+const (
+	KEY_PREFIX    = "todo"
+	KEY_ID_BYTES  = 8
+	SECRET_BYTES  = 32
+	KEY_SEPARATOR = "_"
+	KEY_SCHEME    = "X-API-Key"
+)
+
+var (
+	ErrInvalidKey       = fmt.Errorf("invalid api key")
+	ErrKeyNotFound      = fmt.Errorf("api key not found")
+	ErrKeyRevoked       = fmt.Errorf("api key revoked")
+	ErrEmptyKeyName     = fmt.Errorf("api key name must not be empty")
+	ErrInvalidScope     = fmt.Errorf("invalid scope")
+	ErrNoScopes         = fmt.Errorf("api key needs at least one scope")
+	ErrKeyAlreadyExists = fmt.Errorf("api key already exists")
+
+	scopes = []domain.Scope{domain.ScopeRead, domain.ScopeWrite, domain.ScopeAdmin}
+)
+
+// APIKey is an issued key. Only a hash of its secret is kept, the full key is
+// shown once when it is issued or rotated
+type APIKey struct {
+	Id        string
+	Name      string
+	Scopes    []domain.Scope
+	CreatedAt time.Time
+	RotatedAt time.Time
+	RevokedAt time.Time
+	hash      []byte
+}
+
+func (k *APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+func NewKeyStore() *KeyStore {
+	return &KeyStore{
+		keys: make(map[string]*APIKey),
+	}
+}
+
+// KeyStore issues API keys and authenticates requests made with them. Keys
+// look like todo_<id>_<secret>, so a key is found by its id and then checked
+// against the stored hash of its secret
+type KeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+}
+
+// Issue creates a key with a random secret, returning the full key
+func (s *KeyStore) Issue(name string, scopes []domain.Scope) (string, *APIKey, error) {
+	if err := validateKey(name, scopes); err != nil {
+		return "", nil, err
+	}
+
+	id, err := randomString(KEY_ID_BYTES)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomString(SECRET_BYTES)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return s.add(id, secret, name, scopes)
+}
+
+// Import stores a key generated elsewhere, like one provided in the
+// configuration to bootstrap the first admin
+func (s *KeyStore) Import(key string, name string, scopes []domain.Scope) (*APIKey, error) {
+	if err := validateKey(name, scopes); err != nil {
+		return nil, err
+	}
+
+	id, secret, ok := parseKey(key)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	_, apiKey, err := s.add(id, secret, name, scopes)
+	return apiKey, err
+}
+
+func (s *KeyStore) add(id string, secret string, name string, scopes []domain.Scope) (string, *APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[id]; ok {
+		return "", nil, ErrKeyAlreadyExists
+	}
+
+	key := &APIKey{
+		Id:        id,
+		Name:      name,
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: time.Now(),
+		hash:      hashSecret(secret),
+	}
+	s.keys[id] = key
+
+	return formatKey(id, secret), copyKey(key), nil
+}
+
+// Rotate replaces the secret of a key, returning the new full key
+func (s *KeyStore) Rotate(id string) (string, *APIKey, error) {
+	secret, err := randomString(SECRET_BYTES)
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return "", nil, ErrKeyNotFound
+	}
+	if key.Revoked() {
+		return "", nil, ErrKeyRevoked
+	}
+
+	key.hash = hashSecret(secret)
+	key.RotatedAt = time.Now()
+
+	return formatKey(id, secret), copyKey(key), nil
+}
+
+// Revoke rejects the key from now on. Revoked keys are kept so they are still listed
+func (s *KeyStore) Revoke(id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	if !key.Revoked() {
+		key.RevokedAt = time.Now()
+	}
+
+	return copyKey(key), nil
+}
+
+// List returns every key, oldest first
+func (s *KeyStore) List() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, *copyKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys
+}
+
+// Authenticate returns the principal the API key in creds belongs to
+func (s *KeyStore) Authenticate(ctx context.Context, creds Credentials) (*domain.Principal, error) {
+	if creds.APIKey == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	id, secret, ok := parseKey(creds.APIKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apiKey, ok := s.keys[id]
+	if !ok || subtle.ConstantTimeCompare(apiKey.hash, hashSecret(secret)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if apiKey.Revoked() {
+		return nil, ErrKeyRevoked
+	}
+
+	return &domain.Principal{
+		Id:     KEY_PREFIX + KEY_SEPARATOR + apiKey.Id,
+		Scopes: slices.Clone(apiKey.Scopes),
+	}, nil
+}
+
+func (*KeyStore) Scheme() string {
+	return KEY_SCHEME
+}
+
+func validateKey(name string, keyScopes []domain.Scope) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrEmptyKeyName
+	}
+	if len(keyScopes) == 0 {
+		return ErrNoScopes
+	}
+	for _, scope := range keyScopes {
+		if !slices.Contains(scopes, scope) {
+			return fmt.Errorf("%w %q", ErrInvalidScope, scope)
+		}
+	}
+	return nil
+}
+
+func formatKey(id string, secret string) string {
+	return strings.Join([]string{KEY_PREFIX, id, secret}, KEY_SEPARATOR)
+}
+
+func parseKey(key string) (string, string, bool) {
+	prefix, rest, ok := strings.Cut(key, KEY_SEPARATOR)
+	if !ok || prefix != KEY_PREFIX {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(rest, KEY_SEPARATOR)
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// secrets are long random strings, so a fast hash is enough to make a leaked
+// store useless without the slowness of a password hash on every request
+func hashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// randomString returns n random bytes hex encoded, so it never contains the key separator
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func copyKey(key *APIKey) *APIKey {
+	c := *key
+	c.Scopes = slices.Clone(key.Scopes)
+	c.hash = nil
+	return &c
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+func TestKeyStoreAuthenticate(t *testing.T) {
+	keys := NewKeyStore()
+	ctx := context.Background()
+
+	key, issued, err := keys.Issue("reader", []domain.Scope{domain.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedKey, err := keys.Issue("revoked", []domain.Scope{domain.ScopeWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Revoke(revokedKey.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		err  error
+	}{
+		{name: "issued key", key: key},
+		{name: "missing key", key: "", err: ErrMissingCredentials},
+		{name: "malformed key", key: "not-a-key", err: ErrInvalidKey},
+		{name: "key without a secret", key: KEY_PREFIX + KEY_SEPARATOR + issued.Id + KEY_SEPARATOR, err: ErrInvalidKey},
+		{name: "unknown id", key: formatKey("0123", "secret"), err: ErrInvalidKey},
+		{name: "wrong secret", key: formatKey(issued.Id, "secret"), err: ErrInvalidKey},
+		{name: "revoked key", key: revoked, err: ErrKeyRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := keys.Authenticate(ctx, Credentials{APIKey: tt.key})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if principal.Id != KEY_PREFIX+KEY_SEPARATOR+issued.Id || !slices.Equal(principal.Scopes, issued.Scopes) {
+				t.Errorf("expected the principal of the key, got %+v", principal)
+			}
+		})
+	}
+}
+
+func TestKeyStoreLifecycle(t *testing.T) {
+	keys := NewKeyStore()
+	ctx := context.Background()
+
+	key, issued, err := keys.Issue("writer", []domain.Scope{domain.ScopeRead, domain.ScopeWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, _, err := keys.Rotate(issued.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(ctx, Credentials{APIKey: key}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected %v for the key replaced by rotation, got %v", ErrInvalidKey, err)
+	}
+	if _, err := keys.Authenticate(ctx, Credentials{APIKey: rotated}); err != nil {
+		t.Errorf("unexpected error authenticating the rotated key: %s", err)
+	}
+
+	if _, err := keys.Revoke(issued.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(ctx, Credentials{APIKey: rotated}); !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("expected %v after revoking the key, got %v", ErrKeyRevoked, err)
+	}
+	if _, _, err := keys.Rotate(issued.Id); !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("expected %v rotating a revoked key, got %v", ErrKeyRevoked, err)
+	}
+	// revoking again keeps the first revocation
+	again, err := keys.Revoke(issued.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed := keys.List()
+	if len(listed) != 1 || !listed[0].Revoked() || !listed[0].RevokedAt.Equal(again.RevokedAt) {
+		t.Errorf("expected the revoked key to still be listed, got %+v", listed)
+	}
+
+	if _, _, err := keys.Rotate("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected %v rotating an unknown key, got %v", ErrKeyNotFound, err)
+	}
+	if _, err := keys.Revoke("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected %v revoking an unknown key, got %v", ErrKeyNotFound, err)
+	}
+}
+
+func TestKeyStoreIssueErrors(t *testing.T) {
+	keys := NewKeyStore()
+	admin := formatKey("abc", "secret")
+	if _, err := keys.Import(admin, "admin", []domain.Scope{domain.ScopeAdmin}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		issue func() error
+		err   error
+	}{
+		{
+			name: "empty name",
+			issue: func() error {
+				_, _, err := keys.Issue(" ", []domain.Scope{domain.ScopeRead})
+				return err
+			},
+			err: ErrEmptyKeyName,
+		},
+		{
+			name: "no scopes",
+			issue: func() error {
+				_, _, err := keys.Issue("reader", nil)
+				return err
+			},
+			err: ErrNoScopes,
+		},
+		{
+			name: "unknown scope",
+			issue: func() error {
+				_, _, err := keys.Issue("reader", []domain.Scope{"root"})
+				return err
+			},
+			err: ErrInvalidScope,
+		},
+		{
+			name: "imported key that is malformed",
+			issue: func() error {
+				_, err := keys.Import("secret", "admin", []domain.Scope{domain.ScopeAdmin})
+				return err
+			},
+			err: ErrInvalidKey,
+		},
+		{
+			name: "imported key that already exists",
+			issue: func() error {
+				_, err := keys.Import(admin, "admin", []domain.Scope{domain.ScopeAdmin})
+				return err
+			},
+			err: ErrKeyAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.issue(); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
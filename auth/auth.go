@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+var (
+	ErrMissingCredentials = fmt.Errorf("missing credentials")
+)
+
+// Credentials are what a caller sent to identify itself, taken from HTTP
+// headers or gRPC metadata
+type Credentials struct {
+	APIKey      string
+	BearerToken string
+}
+
+// Authenticator resolves the principal the credentials belong to, returning
+// ErrMissingCredentials if they hold nothing it can check
+type Authenticator interface {
+	Authenticate(ctx context.Context, creds Credentials) (*domain.Principal, error)
+	// Scheme names the credentials expected, for WWW-Authenticate challenges
+	Scheme() string
+}
@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	JWKS_CACHE_TTL = time.Hour
+	// unknown key ids force a refresh at most this often, so tokens with made
+	// up key ids cannot be used to hammer the identity provider
+	JWKS_MIN_REFRESH_INTERVAL = time.Minute
+	JWKS_FETCH_TIMEOUT        = time.Second * 5
+)
+
+var (
+	ErrUnknownKey     = fmt.Errorf("unknown signing key")
+	ErrInvalidJWKS    = fmt.Errorf("invalid json web key set")
+	ErrInvalidKeyFile = fmt.Errorf("invalid public key file")
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newJWKS(url string, client *http.Client) *jwks {
+	return &jwks{
+		url:                url,
+		client:             client,
+		keys:               make(map[string]crypto.PublicKey),
+		minRefreshInterval: JWKS_MIN_REFRESH_INTERVAL,
+	}
+}
+
+// jwks caches the signing keys published by an identity provider
+type jwks struct {
+	url                string
+	client             *http.Client
+	minRefreshInterval time.Duration
+
+	mu   sync.Mutex
+	keys map[string]crypto.PublicKey
+	// fetchedAt is when the keys were last fetched, attemptedAt when a fetch
+	// was last started whether or not it succeeded
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// refreshing is the fetch in flight, nil when there is none
+	refreshing *jwksFetch
+}
+
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// key returns the public key with the id kid, fetching the key set again when
+// the cache has expired or the key is new. The key set is fetched at most once
+// every minRefreshInterval, by one caller while the others wait for it
+func (j *jwks) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	key, ok := j.keys[kid]
+	if ok && time.Since(j.fetchedAt) <= JWKS_CACHE_TTL {
+		j.mu.Unlock()
+		return key, nil
+	}
+
+	fetch := j.refreshing
+	if fetch == nil {
+		if time.Since(j.attemptedAt) < j.minRefreshInterval {
+			j.mu.Unlock()
+			// keep using the cached key until the provider may be asked again
+			if ok {
+				return key, nil
+			}
+			return nil, ErrUnknownKey
+		}
+
+		fetch = &jwksFetch{done: make(chan struct{})}
+		j.refreshing = fetch
+		j.attemptedAt = time.Now()
+		// the fetch outlives the caller that started it, so a caller that goes
+		// away does not fail it for everyone waiting
+		go j.refresh(context.WithoutCancel(ctx), fetch)
+	}
+	j.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// a failed fetch leaves the cached keys in place, so they keep being used
+	// while the provider is unreachable
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	if fetch.err != nil {
+		return nil, fetch.err
+	}
+	return nil, ErrUnknownKey
+}
+
+func (j *jwks) refresh(ctx context.Context, fetch *jwksFetch) {
+	keys, err := j.fetch(ctx)
+
+	j.mu.Lock()
+	if err == nil {
+		j.keys = keys
+		j.fetchedAt = time.Now()
+	}
+	fetch.err = err
+	j.refreshing = nil
+	j.mu.Unlock()
+
+	close(fetch.done)
+}
+
+func (j *jwks) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, JWKS_FETCH_TIMEOUT)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: fetching %s returned %s", ErrInvalidJWKS, j.url, resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJWKS, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// keys of unsupported types are skipped rather than failing the whole set
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: unsupported curve %q", ErrInvalidJWKS, k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: unsupported curve %q", ErrInvalidJWKS, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key", ErrInvalidJWKS)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %q", ErrInvalidJWKS, k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJWKS, err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// LoadPublicKey reads a PEM encoded public key or certificate, for verifying
+// tokens without an identity provider
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKeyFile
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected %s block", ErrInvalidKeyFile, block.Type)
+	}
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DEFAULT_SCOPE_CLAIM = "scope"
+)
+
+var (
+	ErrInvalidToken    = fmt.Errorf("invalid bearer token")
+	ErrNoVerifyingKeys = fmt.Errorf("a jwks url, public key or secret is required to verify tokens")
+	ErrMissingSubject  = fmt.Errorf("token has no subject")
+
+	asymmetricMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+	symmetricMethods  = []string{"HS256", "HS384", "HS512"}
+)
+
+type JWTConfig struct {
+	// JWKSURL is where the identity provider publishes its signing keys
+	JWKSURL string
+	// PublicKey and Secret verify tokens without an identity provider, for
+	// local development and tests. Secret is used when both are set
+	PublicKey crypto.PublicKey
+	Secret    []byte
+	Issuer    string
+	Audience  string
+	// ScopeClaim holds the token's scopes, as a space separated string or
+	// an array. Defaults to DEFAULT_SCOPE_CLAIM
+	ScopeClaim string
+	Client     *http.Client
+}
+
+func NewJWTAuthenticator(cfg *JWTConfig) (*JWTAuthenticator, error) {
+	a := &JWTAuthenticator{
+		scopeClaim: cfg.ScopeClaim,
+	}
+	if a.scopeClaim == "" {
+		a.scopeClaim = DEFAULT_SCOPE_CLAIM
+	}
+
+	options := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	switch {
+	case len(cfg.Secret) > 0:
+		secret := slices.Clone(cfg.Secret)
+		a.keyFunc = func(context.Context, *jwt.Token) (any, error) { return secret, nil }
+		options = append(options, jwt.WithValidMethods(symmetricMethods))
+	case cfg.PublicKey != nil:
+		a.keyFunc = func(context.Context, *jwt.Token) (any, error) { return cfg.PublicKey, nil }
+		options = append(options, jwt.WithValidMethods(asymmetricMethods))
+	case cfg.JWKSURL != "":
+		client := cfg.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		keys := newJWKS(cfg.JWKSURL, client)
+		a.keyFunc = func(ctx context.Context, token *jwt.Token) (any, error) {
+			kid, _ := token.Header["kid"].(string)
+			return keys.key(ctx, kid)
+		}
+		options = append(options, jwt.WithValidMethods(asymmetricMethods))
+	default:
+		return nil, ErrNoVerifyingKeys
+	}
+
+	a.parser = jwt.NewParser(options...)
+	return a, nil
+}
+
+// JWTAuthenticator authenticates users by the bearer tokens issued to them by
+// an identity provider
+type JWTAuthenticator struct {
+	parser     *jwt.Parser
+	keyFunc    func(ctx context.Context, token *jwt.Token) (any, error)
+	scopeClaim string
+}
+
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*domain.Principal, error) {
+	if creds.BearerToken == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	keyFunc := func(token *jwt.Token) (any, error) {
+		return a.keyFunc(ctx, token)
+	}
+	if _, err := a.parser.ParseWithClaims(creds.BearerToken, claims, keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, ErrMissingSubject)
+	}
+
+	return &domain.Principal{
+		Id:     subject,
+		Scopes: tokenScopes(claims[a.scopeClaim]),
+	}, nil
+}
+
+func (*JWTAuthenticator) Scheme() string {
+	return "Bearer"
+}
+
+// tokenScopes keeps the scopes this service knows, ignoring those meant for
+// other services sharing the identity provider
+func tokenScopes(claim any) []domain.Scope {
+	var values []string
+	switch v := claim.(type) {
+	case string:
+		values = strings.Fields(v)
+	case []any:
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+
+	granted := make([]domain.Scope, 0, len(values))
+	for _, value := range values {
+		scope := domain.Scope(value)
+		if slices.Contains(scopes, scope) && !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	return granted
+}
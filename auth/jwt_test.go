@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTAuthenticate(t *testing.T) {
+	signing := newTestSigningKey(t, "current")
+	provider := newTestJWKSServer(t, signing)
+	authn, err := NewJWTAuthenticator(&JWTConfig{
+		JWKSURL:  provider.URL,
+		Issuer:   "https://id.example.com",
+		Audience: "todos",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(change func(c jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":   "user-1",
+			"iss":   "https://id.example.com",
+			"aud":   "todos",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "read write billing",
+		}
+		change(c)
+		return c
+	}
+	forged := newTestSigningKey(t, "current")
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "valid token", token: signing.sign(t, claims(func(jwt.MapClaims) {}))},
+		{name: "missing token", token: "", err: ErrMissingCredentials},
+		{name: "malformed token", token: "not.a.token", err: ErrInvalidToken},
+		{name: "wrong signature", token: forged.sign(t, claims(func(jwt.MapClaims) {})), err: ErrInvalidToken},
+		{name: "wrong issuer", token: signing.sign(t, claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" })), err: ErrInvalidToken},
+		{name: "wrong audience", token: signing.sign(t, claims(func(c jwt.MapClaims) { c["aud"] = "billing" })), err: ErrInvalidToken},
+		{name: "expired", token: signing.sign(t, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })), err: ErrInvalidToken},
+		{name: "no expiry", token: signing.sign(t, claims(func(c jwt.MapClaims) { delete(c, "exp") })), err: ErrInvalidToken},
+		{name: "no subject", token: signing.sign(t, claims(func(c jwt.MapClaims) { delete(c, "sub") })), err: ErrInvalidToken},
+		{name: "unknown key id", token: newTestSigningKey(t, "other").sign(t, claims(func(jwt.MapClaims) {})), err: ErrInvalidToken},
+		{
+			name: "symmetric algorithm",
+			token: func() string {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(func(jwt.MapClaims) {})).SignedString([]byte("secret"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			}(),
+			err: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authn.Authenticate(context.Background(), Credentials{BearerToken: tt.token})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if tt.err != nil {
+				return
+			}
+			// scopes meant for other services are dropped
+			if principal.Id != "user-1" || !slices.Equal(principal.Scopes, []domain.Scope{domain.ScopeRead, domain.ScopeWrite}) {
+				t.Errorf("expected the principal of the token, got %+v", principal)
+			}
+		})
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	old := newTestSigningKey(t, "old")
+	provider := newTestJWKSServer(t, old)
+	keys := newJWKS(provider.URL, provider.Client())
+	keys.minRefreshInterval = 0
+	ctx := context.Background()
+
+	if _, err := keys.key(ctx, "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	provider.publish(newTestSigningKey(t, "new"))
+	if _, err := keys.key(ctx, "new"); err != nil {
+		t.Errorf("expected the rotated key to be fetched, got %v", err)
+	}
+	if _, err := keys.key(ctx, "old"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected %v for the key the provider dropped, got %v", ErrUnknownKey, err)
+	}
+}
+
+func TestJWKSMinRefreshInterval(t *testing.T) {
+	current := newTestSigningKey(t, "current")
+	provider := newTestJWKSServer(t, current)
+	keys := newJWKS(provider.URL, provider.Client())
+	ctx := context.Background()
+
+	if _, err := keys.key(ctx, "current"); err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		if _, err := keys.key(ctx, "made-up"); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("expected %v, got %v", ErrUnknownKey, err)
+		}
+	}
+	if n := provider.requests.Load(); n != 1 {
+		t.Errorf("expected unknown key ids to wait for the refresh interval, the key set was fetched %d times", n)
+	}
+
+	// a failed fetch counts as an attempt, so an unreachable provider is not
+	// asked again on every call while the cached keys keep working
+	provider.fail(true)
+	keys.fetchedAt = time.Now().Add(-JWKS_CACHE_TTL - time.Second)
+	keys.attemptedAt = time.Time{}
+	for range 5 {
+		if _, err := keys.key(ctx, "current"); err != nil {
+			t.Fatalf("expected the cached key while the provider is down, got %v", err)
+		}
+	}
+	if n := provider.requests.Load(); n != 2 {
+		t.Errorf("expected a single attempt while the provider is down, the key set was fetched %d times", n)
+	}
+}
+
+func TestJWKSConcurrentRefresh(t *testing.T) {
+	provider := newTestJWKSServer(t, newTestSigningKey(t, "current"))
+	release := make(chan struct{})
+	provider.wait = release
+	keys := newJWKS(provider.URL, provider.Client())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.key(context.Background(), "current")
+			errs <- err
+		}()
+	}
+
+	// a caller that gives up does not fail the fetch for the others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := keys.key(ctx, "current"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v for the caller that went away, got %v", context.Canceled, err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	if n := provider.requests.Load(); n != 1 {
+		t.Errorf("expected the callers to share one fetch, the key set was fetched %d times", n)
+	}
+}
+
+type testSigningKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+func newTestSigningKey(t *testing.T, kid string) *testSigningKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigningKey{kid: kid, key: key}
+}
+
+func (k *testSigningKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (k *testSigningKey) jwk() jsonWebKey {
+	return jsonWebKey{
+		Kty: "RSA",
+		Kid: k.kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+	}
+}
+
+// testJWKSServer publishes a key set like an identity provider, counting how
+// often it is fetched
+type testJWKSServer struct {
+	*httptest.Server
+	requests atomic.Int32
+	// wait holds every response until it is closed, when set
+	wait chan struct{}
+
+	mu      sync.Mutex
+	keys    []jsonWebKey
+	failing bool
+}
+
+func newTestJWKSServer(t *testing.T, keys ...*testSigningKey) *testJWKSServer {
+	t.Helper()
+
+	s := &testJWKSServer{}
+	s.publish(keys...)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if s.wait != nil {
+			<-s.wait
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]jsonWebKey{"keys": s.keys})
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *testJWKSServer) publish(keys ...*testSigningKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = nil
+	for _, k := range keys {
+		s.keys = append(s.keys, k.jwk())
+	}
+}
+
+func (s *testJWKSServer) fail(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failing = failing
+}
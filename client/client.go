@@ -0,0 +1,242 @@
+// Package client calls the todo REST API from Go. It wraps the client
+// generated from http/openapi.yaml, returning plain values, *APIError for
+// error responses and retrying requests that fail for a moment
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/google/uuid"
+)
+
+const (
+	DEFAULT_MAX_RETRIES = 3
+	DEFAULT_MIN_BACKOFF = 100 * time.Millisecond
+	DEFAULT_MAX_BACKOFF = 5 * time.Second
+	DEFAULT_TIMEOUT     = 30 * time.Second
+
+	API_KEY_HEADER   = "X-API-Key"
+	NAMESPACE_HEADER = "X-Namespace"
+)
+
+var (
+	ErrMissingBaseURL = fmt.Errorf("base url must not be empty")
+	ErrInvalidTodoId  = fmt.Errorf("todo id must be a uuid")
+)
+
+type Config struct {
+	// BaseURL is where the API is served, like http://localhost:8080
+	BaseURL string
+	// HTTPClient sends the requests, a client with DEFAULT_TIMEOUT is used when nil
+	HTTPClient *http.Client
+	// APIKey is sent in the X-API-Key header when the server uses API keys
+	APIKey string
+	// Token is sent as a bearer token when the server uses JWTs
+	Token string
+	// Namespace selects the namespace of every request, the default one when empty
+	Namespace string
+	// MaxRetries is how many times a failed request is retried, DEFAULT_MAX_RETRIES
+	// when 0. Set it below 0 to never retry
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the wait between retries, which doubles
+	// with every attempt
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+type Client struct {
+	api *generated.ClientWithResponses
+}
+
+type Todo struct {
+	Id          string
+	OwnerId     string
+	Description string
+	Done        bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DoneAt      time.Time
+}
+
+func New(config Config) (*Client, error) {
+	if config.BaseURL == "" {
+		return nil, ErrMissingBaseURL
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: DEFAULT_TIMEOUT}
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DEFAULT_MAX_RETRIES
+	}
+	if config.MinBackoff == 0 {
+		config.MinBackoff = DEFAULT_MIN_BACKOFF
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = DEFAULT_MAX_BACKOFF
+	}
+
+	api, err := generated.NewClientWithResponses(config.BaseURL,
+		generated.WithHTTPClient(&retryDoer{
+			client:     config.HTTPClient,
+			maxRetries: config.MaxRetries,
+			minBackoff: config.MinBackoff,
+			maxBackoff: config.MaxBackoff,
+		}),
+		generated.WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+			if config.APIKey != "" {
+				req.Header.Set(API_KEY_HEADER, config.APIKey)
+			}
+			if config.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+config.Token)
+			}
+			if config.Namespace != "" {
+				req.Header.Set(NAMESPACE_HEADER, config.Namespace)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{api: api}, nil
+}
+
+// API returns the generated client, which covers every operation in the spec.
+// It shares the authentication and retries of c; pass its responses to
+// CheckResponse to get the same errors
+func (c *Client) API() *generated.ClientWithResponses {
+	return c.api
+}
+
+func (c *Client) CreateTodo(ctx context.Context, description string, done bool) (*Todo, error) {
+	resp, err := c.api.CreateTodoWithResponse(ctx, generated.CreateTodoJSONRequestBody{
+		Description: description,
+		Done:        &done,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckResponse(resp.HTTPResponse, resp.Body); err != nil {
+		return nil, err
+	}
+
+	return todoValue(resp.JSON200)
+}
+
+func (c *Client) GetTodo(ctx context.Context, id string) (*Todo, error) {
+	todoId, err := parseTodoId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.api.GetTodoWithResponse(ctx, todoId)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckResponse(resp.HTTPResponse, resp.Body); err != nil {
+		return nil, err
+	}
+
+	return todoValue(resp.JSON200)
+}
+
+func (c *Client) ListTodos(ctx context.Context) ([]Todo, error) {
+	resp, err := c.api.GetTodosWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckResponse(resp.HTTPResponse, resp.Body); err != nil {
+		return nil, err
+	}
+	if resp.JSON200 == nil || resp.JSON200.Value == nil {
+		return nil, ErrUnexpected
+	}
+
+	todos := make([]Todo, 0, len(*resp.JSON200.Value))
+	for _, todo := range *resp.JSON200.Value {
+		todos = append(todos, convertTodo(&todo))
+	}
+	return todos, nil
+}
+
+// UpdateTodo replaces the description and done state of the todo
+func (c *Client) UpdateTodo(ctx context.Context, id string, description string, done bool) (*Todo, error) {
+	todoId, err := parseTodoId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.api.UpdateTodoWithResponse(ctx, todoId, generated.UpdateTodoJSONRequestBody{
+		Description: description,
+		Done:        done,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckResponse(resp.HTTPResponse, resp.Body); err != nil {
+		return nil, err
+	}
+
+	return todoValue(resp.JSON200)
+}
+
+// DeleteTodo succeeds for todos that are already gone
+func (c *Client) DeleteTodo(ctx context.Context, id string) error {
+	todoId, err := parseTodoId(id)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.api.DeleteTodoWithResponse(ctx, todoId)
+	if err != nil {
+		return err
+	}
+	return CheckResponse(resp.HTTPResponse, resp.Body)
+}
+
+func parseTodoId(id string) (uuid.UUID, error) {
+	todoId, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("%w: %s", ErrInvalidTodoId, err)
+	}
+	return todoId, nil
+}
+
+func todoValue(resp *generated.TodoResponse) (*Todo, error) {
+	if resp == nil || resp.Value == nil {
+		return nil, ErrUnexpected
+	}
+
+	todo := convertTodo(resp.Value)
+	return &todo, nil
+}
+
+func convertTodo(todo *generated.Todo) Todo {
+	t := Todo{
+		OwnerId:     valueOrZero(todo.OwnerId),
+		Description: valueOrZero(todo.Description),
+		Done:        valueOrZero(todo.Done),
+		CreatedAt:   valueOrZero(todo.CreatedAt),
+		UpdatedAt:   valueOrZero(todo.UpdatedAt),
+		DoneAt:      valueOrZero(todo.DoneAt),
+	}
+	if todo.Id != nil {
+		t.Id = todo.Id.String()
+	}
+	return t
+}
+
+func valueOrZero[T any](val *T) T {
+	var zero T
+	if val == nil {
+		return zero
+	}
+
+	return *val
+}
@@ -0,0 +1,374 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/events"
+	server "github.com/brendenehlers/todo-microservice/http"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/brendenehlers/todo-microservice/memory"
+	"github.com/brendenehlers/todo-microservice/sharing"
+	"github.com/brendenehlers/todo-microservice/slogger"
+	"github.com/brendenehlers/todo-microservice/tenant"
+	"github.com/google/uuid"
+)
+
+func TestTodoRoundTrip(t *testing.T) {
+	c := newTestClient(t, newTestServer(t, testServer{}), Config{})
+	ctx := context.Background()
+
+	created, err := c.CreateTodo(ctx, "write tests", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Id == "" || created.Description != "write tests" || created.Done {
+		t.Fatalf("unexpected created todo: %+v", created)
+	}
+
+	got, err := c.GetTodo(ctx, created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != created.Id || got.Description != created.Description {
+		t.Errorf("expected %+v, got %+v", created, got)
+	}
+
+	updated, err := c.UpdateTodo(ctx, created.Id, "write more tests", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Description != "write more tests" || !updated.Done || updated.DoneAt.IsZero() {
+		t.Errorf("unexpected updated todo: %+v", updated)
+	}
+
+	todos, err := c.ListTodos(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 1 || todos[0].Id != created.Id || todos[0].Description != updated.Description {
+		t.Errorf("expected only the updated todo, got %+v", todos)
+	}
+
+	if err := c.DeleteTodo(ctx, created.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTodo(ctx, created.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v after deleting the todo, got %v", ErrNotFound, err)
+	}
+	// deleting is idempotent
+	if err := c.DeleteTodo(ctx, created.Id); err != nil {
+		t.Errorf("unexpected error deleting the todo again: %s", err)
+	}
+
+	if _, err := c.GetTodo(ctx, "not-a-uuid"); !errors.Is(err, ErrInvalidTodoId) {
+		t.Errorf("expected %v, got %v", ErrInvalidTodoId, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	url := newTestServer(t, testServer{
+		rateLimits: map[string]server.RateLimit{
+			"getTodos": {Rate: 0.001, Burst: 1},
+		},
+	})
+	c := newTestClient(t, url, Config{MaxRetries: -1})
+	ctx := context.Background()
+
+	if _, err := c.API().CreateNamespaceWithResponse(ctx, generated.CreateNamespaceJSONRequestBody{Name: "taken"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListTodos(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		call   func() error
+		err    error
+		status int
+	}{
+		{
+			name: "not found",
+			call: func() error {
+				_, err := c.GetTodo(ctx, uuid.NewString())
+				return err
+			},
+			err:    ErrNotFound,
+			status: http.StatusNotFound,
+		},
+		{
+			name: "bad request",
+			call: func() error {
+				_, err := c.CreateTodo(ctx, "", false)
+				return err
+			},
+			err:    ErrBadRequest,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "conflict",
+			call: func() error {
+				resp, err := c.API().CreateNamespaceWithResponse(ctx, generated.CreateNamespaceJSONRequestBody{Name: "taken"})
+				if err != nil {
+					return err
+				}
+				return CheckResponse(resp.HTTPResponse, resp.Body)
+			},
+			err:    ErrConflict,
+			status: http.StatusConflict,
+		},
+		{
+			name: "too many requests",
+			call: func() error {
+				_, err := c.ListTodos(ctx)
+				return err
+			},
+			err:    ErrTooManyRequests,
+			status: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected an *APIError, got %T", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, apiErr.StatusCode)
+			}
+			if apiErr.RequestID == "" {
+				t.Error("expected the request id of the response")
+			}
+			if tt.status == http.StatusBadRequest && len(apiErr.Violations) == 0 {
+				t.Error("expected the violations of the request")
+			}
+			if tt.status == http.StatusTooManyRequests && apiErr.RetryAfter <= 0 {
+				t.Error("expected the Retry-After of the response")
+			}
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("idempotent requests are retried after a server error", func(t *testing.T) {
+		var attempts atomic.Int32
+		url := newTestServer(t, testServer{wrap: failFirst(2, &attempts)})
+		c := newTestClient(t, url, Config{MinBackoff: 20 * time.Millisecond, MaxBackoff: time.Second})
+
+		start := time.Now()
+		if _, err := c.ListTodos(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if n := attempts.Load(); n != 3 {
+			t.Errorf("expected 3 attempts, got %d", n)
+		}
+		// the waits are at least half of 20ms and then 40ms
+		if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+			t.Errorf("expected the retries to back off, they took %s", elapsed)
+		}
+	})
+
+	t.Run("retries stop after MaxRetries", func(t *testing.T) {
+		var attempts atomic.Int32
+		url := newTestServer(t, testServer{wrap: failFirst(10, &attempts)})
+		c := newTestClient(t, url, Config{MaxRetries: 2})
+
+		_, err := c.ListTodos(ctx)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || !errors.Is(err, ErrServer) {
+			t.Fatalf("expected a %d error, got %v", http.StatusServiceUnavailable, err)
+		}
+		if n := attempts.Load(); n != 3 {
+			t.Errorf("expected 3 attempts, got %d", n)
+		}
+	})
+
+	t.Run("other requests are not retried after a server error", func(t *testing.T) {
+		var attempts atomic.Int32
+		url := newTestServer(t, testServer{wrap: failFirst(1, &attempts)})
+		c := newTestClient(t, url, Config{})
+
+		if _, err := c.CreateTodo(ctx, "once", false); !errors.Is(err, ErrServer) {
+			t.Fatalf("expected %v, got %v", ErrServer, err)
+		}
+		if n := attempts.Load(); n != 1 {
+			t.Errorf("expected 1 attempt, got %d", n)
+		}
+	})
+
+	t.Run("rate limited requests wait for Retry-After", func(t *testing.T) {
+		url := newTestServer(t, testServer{
+			rateLimits: map[string]server.RateLimit{
+				"createTodo": {Rate: 10, Burst: 1},
+			},
+		})
+		c := newTestClient(t, url, Config{})
+
+		start := time.Now()
+		for _, description := range []string{"first", "second"} {
+			if _, err := c.CreateTodo(ctx, description, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Retry-After is sent in whole seconds
+		if elapsed := time.Since(start); elapsed < time.Second {
+			t.Errorf("expected the second request to wait for Retry-After, it took %s", elapsed)
+		}
+	})
+
+	t.Run("quota errors are not retried", func(t *testing.T) {
+		url := newTestServer(t, testServer{tenants: tenant.Config{MaxTodos: 1}})
+		c := newTestClient(t, url, Config{})
+
+		if _, err := c.API().CreateNamespaceWithResponse(ctx, generated.CreateNamespaceJSONRequestBody{Name: "small"}); err != nil {
+			t.Fatal(err)
+		}
+		small := newTestClient(t, url, Config{Namespace: "small"})
+		if _, err := small.CreateTodo(ctx, "fits", false); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := small.CreateTodo(ctx, "does not fit", false)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !errors.Is(err, ErrTooManyRequests) || apiErr.RetryAfter != 0 {
+			t.Fatalf("expected a quota error without Retry-After, got %v", err)
+		}
+	})
+}
+
+func TestContextCancellation(t *testing.T) {
+	t.Run("while waiting to retry", func(t *testing.T) {
+		var attempts atomic.Int32
+		url := newTestServer(t, testServer{wrap: failFirst(10, &attempts)})
+		c := newTestClient(t, url, Config{MinBackoff: time.Hour, MaxBackoff: time.Hour})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if _, err := c.ListTodos(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected the backoff to end with the context, it took %s", elapsed)
+		}
+		if n := attempts.Load(); n != 1 {
+			t.Errorf("expected 1 attempt, got %d", n)
+		}
+	})
+
+	t.Run("while waiting for the response", func(t *testing.T) {
+		url := newTestServer(t, testServer{wrap: blockUntilCanceled})
+		c := newTestClient(t, url, Config{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		if _, err := c.ListTodos(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected %v, got %v", context.Canceled, err)
+		}
+	})
+}
+
+// testServer configures newTestServer
+type testServer struct {
+	tenants    tenant.Config
+	rateLimits map[string]server.RateLimit
+	// wrap wraps the router, to fail or hold up requests before they reach it
+	wrap func(next http.Handler) http.Handler
+}
+
+// newTestServer serves the REST API from the router the app uses, returning
+// its url
+func newTestServer(t *testing.T, opts testServer) string {
+	t.Helper()
+
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	namespaces, err := tenant.New(func(string) (domain.TodoRepository, error) {
+		return memory.New(log), nil
+	}, &opts.tenants)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wired like the app, where the sharing policy makes deletes idempotent
+	repo := events.New(namespaces, log)
+	srv, err := server.CreateHTTPServer(&server.HTTPServerConfig{
+		Repo:            sharing.New(repo),
+		Events:          repo,
+		Log:             log,
+		Namespaces:      namespaces,
+		RouteRateLimits: opts.rateLimits,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handler http.Handler = srv.Handler
+	if opts.wrap != nil {
+		handler = opts.wrap(handler)
+	}
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+// newTestClient keeps the backoff short so retries do not slow the tests down
+func newTestClient(t *testing.T, url string, config Config) *Client {
+	t.Helper()
+
+	config.BaseURL = url
+	if config.MinBackoff == 0 {
+		config.MinBackoff = time.Millisecond
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = 10 * time.Millisecond
+	}
+
+	c, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// failFirst answers the first failures requests with a 503 instead of passing
+// them on, counting every request in attempts
+func failFirst(failures int32, attempts *atomic.Int32) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) <= failures {
+				http.Error(w, "try again later", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// blockUntilCanceled holds every request until the client gives up on it
+func blockUntilCanceled(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+}
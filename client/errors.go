@@ -0,0 +1,106 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/http/generated"
+)
+
+var (
+	ErrBadRequest      = fmt.Errorf("bad request")
+	ErrUnauthorized    = fmt.Errorf("unauthorized")
+	ErrForbidden       = fmt.Errorf("forbidden")
+	ErrNotFound        = fmt.Errorf("not found")
+	ErrConflict        = fmt.Errorf("conflict")
+	ErrGone            = fmt.Errorf("gone")
+	ErrRequestTooLarge = fmt.Errorf("request too large")
+	ErrTooManyRequests = fmt.Errorf("too many requests")
+	ErrServer          = fmt.Errorf("server error")
+	ErrUnexpected      = fmt.Errorf("unexpected response")
+
+	statusErrors = map[int]error{
+		http.StatusBadRequest:            ErrBadRequest,
+		http.StatusUnauthorized:          ErrUnauthorized,
+		http.StatusForbidden:             ErrForbidden,
+		http.StatusNotFound:              ErrNotFound,
+		http.StatusConflict:              ErrConflict,
+		http.StatusGone:                  ErrGone,
+		http.StatusRequestEntityTooLarge: ErrRequestTooLarge,
+		http.StatusTooManyRequests:       ErrTooManyRequests,
+	}
+)
+
+// APIError is returned for every response with an error status. It matches
+// the Err* value of its status with errors.Is, so callers can check for
+// ErrNotFound without looking at status codes
+type APIError struct {
+	StatusCode int
+	// Message is the error the server sent, or the status text if it sent none
+	Message   string
+	RequestID string
+	// Violations lists each part of a rejected request that does not match the spec
+	Violations []generated.Violation
+	// RetryAfter is how long the server asked to wait before retrying, 0 when unset
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID == "" {
+		return fmt.Sprintf("%d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%d: %s (request %s)", e.StatusCode, e.Message, e.RequestID)
+}
+
+func (e *APIError) Unwrap() error {
+	if err, ok := statusErrors[e.StatusCode]; ok {
+		return err
+	}
+	if e.StatusCode >= http.StatusInternalServerError {
+		return ErrServer
+	}
+	return ErrUnexpected
+}
+
+// CheckResponse returns an *APIError when resp has an error status, reading
+// the error the server sent from body. It is exported for callers that use
+// the generated client from API directly
+func CheckResponse(resp *http.Response, body []byte) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+		RetryAfter: retryAfter(resp),
+	}
+
+	var errResp generated.Error
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		if errResp.Error != nil {
+			apiErr.Message = *errResp.Error
+		}
+		if errResp.RequestId != nil {
+			apiErr.RequestID = *errResp.RequestId
+		}
+		if errResp.Violations != nil {
+			apiErr.Violations = *errResp.Violations
+		}
+	}
+
+	return apiErr
+}
+
+// retryAfter reads the Retry-After header in its seconds form, which is the
+// only one the server sends
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
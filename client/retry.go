@@ -0,0 +1,110 @@
+package client
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// retryableStatuses are failures that may pass on a later attempt
+var retryableStatuses = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// only idempotent methods are retried after network errors and retryable
+// statuses, as the server may have handled the request before it failed.
+// Rate limited requests were never handled, so they are retried whatever
+// their method
+var idempotentMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodPut,
+	http.MethodDelete,
+}
+
+// retryDoer sends requests with client, retrying failed attempts with
+// exponential backoff and jitter until maxRetries is reached or the context
+// of the request is done
+type retryDoer struct {
+	client     *http.Client
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+func (d *retryDoer) Do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := d.client.Do(req)
+
+		wait, retry := d.shouldRetry(req, resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+		if resp != nil {
+			// drain the body so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// shouldRetry returns how long to wait before retrying the attempt, if it
+// should be retried at all
+func (d *retryDoer) shouldRetry(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= d.maxRetries || req.Context().Err() != nil {
+		return 0, false
+	}
+	// a body that cannot be read again cannot be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+
+	if err != nil {
+		return d.backoff(attempt), slices.Contains(idempotentMethods, req.Method)
+	}
+
+	// quota errors share the status of rate limits but will not pass later,
+	// they are told apart by the missing Retry-After
+	if resp.StatusCode == http.StatusTooManyRequests {
+		wait := retryAfter(resp)
+		return max(wait, d.backoff(attempt)), wait > 0
+	}
+	if slices.Contains(retryableStatuses, resp.StatusCode) {
+		return max(retryAfter(resp), d.backoff(attempt)), slices.Contains(idempotentMethods, req.Method)
+	}
+	return 0, false
+}
+
+// backoff doubles with every attempt up to maxBackoff, picking a random wait
+// in the upper half so clients that failed together do not retry together
+func (d *retryDoer) backoff(attempt int) time.Duration {
+	wait := d.minBackoff
+	for i := 0; i < attempt && wait < d.maxBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, d.maxBackoff)
+	if wait <= 0 {
+		return 0
+	}
+
+	return wait/2 + rand.N(wait/2+1)
+}
@@ -2,26 +2,285 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/brendenehlers/todo-microservice/auth"
+	"github.com/brendenehlers/todo-microservice/config"
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/events"
+	"github.com/brendenehlers/todo-microservice/grpc"
+	"github.com/brendenehlers/todo-microservice/health"
 	"github.com/brendenehlers/todo-microservice/http"
 	"github.com/brendenehlers/todo-microservice/memory"
+	"github.com/brendenehlers/todo-microservice/metrics"
+	"github.com/brendenehlers/todo-microservice/sharing"
 	"github.com/brendenehlers/todo-microservice/slogger"
+	"github.com/brendenehlers/todo-microservice/tenant"
+	"github.com/brendenehlers/todo-microservice/tracing"
 )
 
 func main() {
-	log := slogger.New()
-	repo := memory.New(log)
-
-	ctx := context.Background()
-	server, err := http.CreateHTTPServer(&http.HTTPServerConfig{
-		Addr: ":8080",
-		Repo: repo,
-		Ctx:  ctx,
-		Log:  log,
+	cfg, opts, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if opts.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	os.Exit(run(cfg))
+}
+
+// run starts the servers and blocks until a shutdown signal or a server
+// failure, returning the process exit code
+func run(cfg *config.Config) int {
+	log, err := slogger.New(cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// every namespace gets its own repository from the configured backend
+	backend, err := tenant.New(func(string) (domain.TodoRepository, error) {
+		return newRepository(&cfg.Storage, log)
+	}, &tenant.Config{
+		MaxNamespaces: cfg.Tenants.MaxNamespaces,
+		MaxTodos:      cfg.Tenants.MaxTodos,
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return 1
+	}
+	shutdownTracing, err := tracing.New(context.Background(), &tracing.TracingConfig{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return 1
+	}
+
+	m := metrics.New()
+	repo := events.New(m.Repository(tracing.Repository(backend), backend.Namespaces), log)
+	policy := sharing.New(repo)
+	backend.OnClear(policy.ClearNamespace)
+
+	checks := health.New(cfg.Health.CacheTTL.Duration(), cfg.Health.CheckTimeout.Duration())
+	checks.Register("storage", backend.HealthCheck)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	authn, keys, err := newAuthenticator(&cfg.Auth)
+	if err != nil {
+		log.Error(err.Error())
+		return 1
+	}
+
+	// without authentication there is no one to share with
+	var shares *sharing.Policy
+	if authn != nil {
+		shares = policy
+	}
+
+	httpServer, err := http.CreateHTTPServer(&http.HTTPServerConfig{
+		Addr:              cfg.HTTP.Addr,
+		Repo:              policy,
+		Events:            repo,
+		Log:               log,
+		Health:            checks,
+		Metrics:           m,
+		RequestTimeout:    cfg.HTTP.RequestTimeout.Duration(),
+		RouteTimeouts:     config.Durations(cfg.HTTP.RouteTimeouts),
+		ReadTimeout:       cfg.HTTP.ReadTimeout.Duration(),
+		WriteTimeout:      cfg.HTTP.WriteTimeout.Duration(),
+		IdleTimeout:       cfg.HTTP.IdleTimeout.Duration(),
+		ValidateResponses: cfg.HTTP.ValidateResponses,
+		RateLimit: http.RateLimit{
+			Rate:  cfg.RateLimit.Rate,
+			Burst: cfg.RateLimit.Burst,
+		},
+		RouteRateLimits: routeRateLimits(cfg.RateLimit.Routes),
+		AuthFailureLimit: http.RateLimit{
+			Rate:  cfg.RateLimit.AuthFailures.Rate,
+			Burst: cfg.RateLimit.AuthFailures.Burst,
+		},
+		CORS: http.CORSConfig{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge.Duration(),
+		},
+		TLS: http.TLSConfig{
+			CertFile:       cfg.HTTP.TLS.CertFile,
+			KeyFile:        cfg.HTTP.TLS.KeyFile,
+			ClientCAFile:   cfg.HTTP.TLS.ClientCAFile,
+			ReloadInterval: cfg.HTTP.TLS.ReloadInterval.Duration(),
+			RedirectAddr:   cfg.HTTP.TLS.RedirectAddr,
+		},
+		Auth:       authn,
+		APIKeys:    keys,
+		Namespaces: backend,
+		Sharing:    shares,
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return 1
+	}
+
+	grpcServer, err := grpc.CreateGRPCServer(&grpc.GRPCServerConfig{
+		Addr:       cfg.GRPC.Addr,
+		Repo:       policy,
+		Log:        log,
+		Health:     checks,
+		Auth:       authn,
+		Namespaces: backend,
 	})
 	if err != nil {
-		panic(err)
+		log.Error(err.Error())
+		return 1
+	}
+
+	servers := []domain.Server{httpServer, grpcServer}
+	errch := make(chan error, len(servers))
+	for _, server := range servers {
+		go func() {
+			errch <- server.Run()
+		}()
+	}
+
+	// a server exiting on its own means it failed to start or crashed, take
+	// the others down with it
+	code := 0
+	select {
+	case <-ctx.Done():
+		log.Info("Received shutdown signal")
+	case err := <-errch:
+		if err != nil {
+			log.Error(err.Error())
+		}
+		code = 1
+	}
+	stop()
+
+	// report unavailable before closing the listeners so load balancers stop
+	// routing new requests here
+	httpServer.SetReady(false)
+	grpcServer.SetReady(false)
+	time.Sleep(cfg.HTTP.ShutdownDelay.Duration())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout.Duration())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Stop(shutdownCtx); err != nil {
+				log.Error(err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := backend.Close(); err != nil {
+		log.Error(err.Error())
+		code = 1
+	}
+
+	// flush spans from the last requests before exiting
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("Shutdown complete")
+	return code
+}
+
+func newRepository(cfg *config.StorageConfig, log domain.Logger) (domain.TodoRepository, error) {
+	switch cfg.Backend {
+	case config.BACKEND_MEMORY:
+		return memory.New(log), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", cfg.Backend)
+	}
+}
+
+// newAuthenticator returns nil when authentication is disabled. The key store
+// is only returned in API key mode, where it also backs the key endpoints
+func newAuthenticator(cfg *config.AuthConfig) (auth.Authenticator, *auth.KeyStore, error) {
+	switch cfg.Mode {
+	case config.AUTH_MODE_NONE:
+		return nil, nil, nil
+	case config.AUTH_MODE_APIKEY:
+		keys := auth.NewKeyStore()
+		if cfg.AdminKey != "" {
+			if _, err := keys.Import(cfg.AdminKey, "admin", []domain.Scope{domain.ScopeAdmin}); err != nil {
+				return nil, nil, fmt.Errorf("auth.adminKey: %w", err)
+			}
+			return keys, keys, nil
+		}
+
+		key, _, err := keys.Issue("admin", []domain.Scope{domain.ScopeAdmin})
+		if err != nil {
+			return nil, nil, err
+		}
+		// the key is a credential, so it is shown once on the terminal rather
+		// than kept in the logs
+		fmt.Fprintf(os.Stderr, "Generated an admin API key, set auth.adminKey to keep it across restarts:\n%s\n", key)
+		return keys, keys, nil
+	case config.AUTH_MODE_JWT:
+		jwtCfg := &auth.JWTConfig{
+			JWKSURL:    cfg.JWKSURL,
+			Secret:     []byte(cfg.JWTSecret),
+			Issuer:     cfg.Issuer,
+			Audience:   cfg.Audience,
+			ScopeClaim: cfg.ScopeClaim,
+		}
+		if cfg.JWTKeyFile != "" {
+			key, err := auth.LoadPublicKey(cfg.JWTKeyFile)
+			if err != nil {
+				return nil, nil, fmt.Errorf("auth.jwtKeyFile: %w", err)
+			}
+			jwtCfg.PublicKey = key
+		}
+
+		authn, err := auth.NewJWTAuthenticator(jwtCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return authn, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported auth mode %q", cfg.Mode)
+	}
+}
+
+// routeRateLimits converts the per operation rate limits of the config
+func routeRateLimits(routes map[string]config.RateLimit) map[string]http.RateLimit {
+	limits := make(map[string]http.RateLimit, len(routes))
+	for op, limit := range routes {
+		limits[op] = http.RateLimit{Rate: limit.Rate, Burst: limit.Burst}
 	}
 
-	server.Run()
+	return limits
 }
@@ -0,0 +1,410 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	BACKEND_MEMORY = "memory"
+
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+
+	TRACING_EXPORTER_NONE   = "none"
+	TRACING_EXPORTER_OTLP   = "otlp"
+	TRACING_EXPORTER_STDOUT = "stdout"
+
+	AUTH_MODE_NONE   = "none"
+	AUTH_MODE_APIKEY = "apikey"
+	AUTH_MODE_JWT    = "jwt"
+
+	// REDACTED replaces secrets when the configuration is printed
+	REDACTED = "***"
+)
+
+var (
+	ErrInvalidConfig = fmt.Errorf("invalid configuration")
+
+	backends   = []string{BACKEND_MEMORY}
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	logFormats = []string{LOG_FORMAT_TEXT, LOG_FORMAT_JSON}
+	authModes  = []string{AUTH_MODE_NONE, AUTH_MODE_APIKEY, AUTH_MODE_JWT}
+	exporters  = []string{TRACING_EXPORTER_NONE, TRACING_EXPORTER_OTLP, TRACING_EXPORTER_STDOUT}
+
+	// keyValueDSN matches connection strings of key=value pairs, like
+	// "host=db user=todo password=secret", and dsnPassword their password.
+	// queryPassword matches the password in the query of a URL
+	keyValueDSN   = regexp.MustCompile(`^\s*\w+\s*=`)
+	dsnPassword   = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+	queryPassword = regexp.MustCompile(`(?i)((?:^|&)password=)[^&]*`)
+)
+
+type Config struct {
+	HTTP    HTTPConfig    `yaml:"http" toml:"http"`
+	GRPC    GRPCConfig    `yaml:"grpc" toml:"grpc"`
+	Storage StorageConfig `yaml:"storage" toml:"storage"`
+	Health  HealthConfig  `yaml:"health" toml:"health"`
+	Tracing TracingConfig `yaml:"tracing" toml:"tracing"`
+	Log     LogConfig     `yaml:"log" toml:"log"`
+	CORS    CORSConfig    `yaml:"cors" toml:"cors"`
+	Auth    AuthConfig    `yaml:"auth" toml:"auth"`
+	Tenants TenantsConfig `yaml:"tenants" toml:"tenants"`
+	// RateLimit applies to each client of each REST operation, separately
+	RateLimit RateLimitConfig `yaml:"rateLimit" toml:"rateLimit"`
+}
+
+type HTTPConfig struct {
+	Addr           string   `yaml:"addr" toml:"addr"`
+	RequestTimeout Duration `yaml:"requestTimeout" toml:"requestTimeout"`
+	// RouteTimeouts overrides RequestTimeout per OpenAPI operationId, 0 disables the timeout
+	RouteTimeouts   map[string]Duration `yaml:"routeTimeouts" toml:"routeTimeouts"`
+	ReadTimeout     Duration            `yaml:"readTimeout" toml:"readTimeout"`
+	WriteTimeout    Duration            `yaml:"writeTimeout" toml:"writeTimeout"`
+	IdleTimeout     Duration            `yaml:"idleTimeout" toml:"idleTimeout"`
+	ShutdownDelay   Duration            `yaml:"shutdownDelay" toml:"shutdownDelay"`
+	ShutdownTimeout Duration            `yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+	// ValidateResponses logs responses that do not match the OpenAPI spec
+	ValidateResponses bool      `yaml:"validateResponses" toml:"validateResponses"`
+	TLS               TLSConfig `yaml:"tls" toml:"tls"`
+}
+
+// TLSConfig serves the REST API over HTTPS when CertFile and KeyFile are set
+type TLSConfig struct {
+	CertFile string `yaml:"certFile" toml:"certFile"`
+	KeyFile  string `yaml:"keyFile" toml:"keyFile"`
+	// ClientCAFile is a CA bundle that clients must present a certificate signed
+	// by, except to the probes and metrics
+	ClientCAFile string `yaml:"clientCaFile" toml:"clientCaFile"`
+	// ReloadInterval is how often the files are checked for changes, 0 disables reloading
+	ReloadInterval Duration `yaml:"reloadInterval" toml:"reloadInterval"`
+	// RedirectAddr is where plain HTTP requests are redirected to HTTPS, empty to disable
+	RedirectAddr string `yaml:"redirectAddr" toml:"redirectAddr"`
+}
+
+type GRPCConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+}
+
+type StorageConfig struct {
+	Backend string `yaml:"backend" toml:"backend"`
+	// DSN may hold the password of the backend, which is masked when the
+	// configuration is printed
+	DSN string `yaml:"dsn" toml:"dsn"`
+}
+
+type HealthConfig struct {
+	// CacheTTL is how long a check result is reused before the check runs again
+	CacheTTL     Duration `yaml:"cacheTtl" toml:"cacheTtl"`
+	CheckTimeout Duration `yaml:"checkTimeout" toml:"checkTimeout"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint"`
+	Insecure    bool    `yaml:"insecure" toml:"insecure"`
+	SampleRatio float64 `yaml:"sampleRatio" toml:"sampleRatio"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowedOrigins" toml:"allowedOrigins"`
+	AllowedMethods   []string `yaml:"allowedMethods" toml:"allowedMethods"`
+	AllowedHeaders   []string `yaml:"allowedHeaders" toml:"allowedHeaders"`
+	AllowCredentials bool     `yaml:"allowCredentials" toml:"allowCredentials"`
+	MaxAge           Duration `yaml:"maxAge" toml:"maxAge"`
+}
+
+type AuthConfig struct {
+	Mode string `yaml:"mode" toml:"mode"`
+	// AdminKey is an API key with the admin scope to issue the other keys
+	// with. One is generated and printed to stderr at startup when it is empty
+	AdminKey string `yaml:"adminKey" toml:"adminKey"`
+	JWKSURL  string `yaml:"jwksUrl" toml:"jwksUrl"`
+	// JWTKeyFile and JWTSecret verify tokens locally instead of fetching the
+	// keys from JWKSURL
+	JWTKeyFile string `yaml:"jwtKeyFile" toml:"jwtKeyFile"`
+	JWTSecret  string `yaml:"jwtSecret" toml:"jwtSecret"`
+	Issuer     string `yaml:"issuer" toml:"issuer"`
+	Audience   string `yaml:"audience" toml:"audience"`
+	ScopeClaim string `yaml:"scopeClaim" toml:"scopeClaim"`
+}
+
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+}
+
+type RateLimitConfig struct {
+	// Rate is how many requests per second are allowed, 0 disables the limit
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+	// Routes overrides the limit per OpenAPI operationId
+	Routes map[string]RateLimit `yaml:"routes" toml:"routes"`
+	// AuthFailures limits the rejected authentication attempts of each IP
+	// address, across every operation
+	AuthFailures RateLimit `yaml:"authFailures" toml:"authFailures"`
+}
+
+type TenantsConfig struct {
+	MaxNamespaces int `yaml:"maxNamespaces" toml:"maxNamespaces"`
+	// MaxTodos is the quota of new namespaces, 0 means unlimited
+	MaxTodos int `yaml:"maxTodos" toml:"maxTodos"`
+}
+
+// Default returns the configuration used for any setting that is not provided
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Addr:            ":8080",
+			RequestTimeout:  Duration(200 * time.Millisecond),
+			ReadTimeout:     Duration(10 * time.Second),
+			IdleTimeout:     Duration(2 * time.Minute),
+			ShutdownTimeout: Duration(5 * time.Second),
+			TLS: TLSConfig{
+				ReloadInterval: Duration(10 * time.Second),
+			},
+		},
+		GRPC: GRPCConfig{
+			Addr: ":9090",
+		},
+		Storage: StorageConfig{
+			Backend: BACKEND_MEMORY,
+		},
+		Health: HealthConfig{
+			CacheTTL:     Duration(5 * time.Second),
+			CheckTimeout: Duration(time.Second),
+		},
+		Tracing: TracingConfig{
+			Exporter:    TRACING_EXPORTER_NONE,
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LOG_FORMAT_TEXT,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+			AllowedHeaders: []string{"Content-Type", "Authorization"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		Auth: AuthConfig{
+			Mode: AUTH_MODE_NONE,
+		},
+		Tenants: TenantsConfig{
+			MaxNamespaces: 100,
+			MaxTodos:      1000,
+		},
+		RateLimit: RateLimitConfig{
+			Rate:  20,
+			Burst: 40,
+			AuthFailures: RateLimit{
+				Rate:  1,
+				Burst: 10,
+			},
+		},
+	}
+}
+
+// Validate reports every invalid setting at once so they can be fixed together
+func (c *Config) Validate() error {
+	errs := make([]error, 0)
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidConfig}, args...)...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.HTTP.Addr); err != nil {
+		invalid("http.addr %q: %s", c.HTTP.Addr, err)
+	}
+	if _, _, err := net.SplitHostPort(c.GRPC.Addr); err != nil {
+		invalid("grpc.addr %q: %s", c.GRPC.Addr, err)
+	}
+	if c.HTTP.RequestTimeout <= 0 {
+		invalid("http.requestTimeout must be positive")
+	}
+	for op, d := range c.HTTP.RouteTimeouts {
+		if d < 0 {
+			invalid("http.routeTimeouts %q must not be negative", op)
+		}
+	}
+	for _, d := range []struct {
+		name string
+		val  Duration
+	}{
+		{"http.readTimeout", c.HTTP.ReadTimeout},
+		{"http.writeTimeout", c.HTTP.WriteTimeout},
+		{"http.idleTimeout", c.HTTP.IdleTimeout},
+		{"http.shutdownDelay", c.HTTP.ShutdownDelay},
+		{"http.shutdownTimeout", c.HTTP.ShutdownTimeout},
+		{"http.tls.reloadInterval", c.HTTP.TLS.ReloadInterval},
+		{"cors.maxAge", c.CORS.MaxAge},
+		{"health.cacheTtl", c.Health.CacheTTL},
+	} {
+		if d.val < 0 {
+			invalid("%s must not be negative", d.name)
+		}
+	}
+
+	tls := c.HTTP.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		invalid("http.tls.certFile and http.tls.keyFile must be set together")
+	}
+	if tls.CertFile == "" && tls.ClientCAFile != "" {
+		invalid("http.tls.clientCaFile requires http.tls.certFile and http.tls.keyFile")
+	}
+	if tls.RedirectAddr != "" {
+		if tls.CertFile == "" {
+			invalid("http.tls.redirectAddr requires http.tls.certFile and http.tls.keyFile")
+		}
+		if _, _, err := net.SplitHostPort(tls.RedirectAddr); err != nil {
+			invalid("http.tls.redirectAddr %q: %s", tls.RedirectAddr, err)
+		}
+	}
+
+	if !slices.Contains(backends, c.Storage.Backend) {
+		invalid("storage.backend %q must be one of %s", c.Storage.Backend, strings.Join(backends, ", "))
+	}
+	if c.Health.CheckTimeout <= 0 {
+		invalid("health.checkTimeout must be positive")
+	}
+	if !slices.Contains(exporters, c.Tracing.Exporter) {
+		invalid("tracing.exporter %q must be one of %s", c.Tracing.Exporter, strings.Join(exporters, ", "))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sampleRatio must be between 0 and 1")
+	}
+	if !slices.Contains(logLevels, c.Log.Level) {
+		invalid("log.level %q must be one of %s", c.Log.Level, strings.Join(logLevels, ", "))
+	}
+	if !slices.Contains(logFormats, c.Log.Format) {
+		invalid("log.format %q must be one of %s", c.Log.Format, strings.Join(logFormats, ", "))
+	}
+
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		invalid("cors.allowCredentials cannot be used with the \"*\" origin")
+	}
+
+	if c.Tenants.MaxNamespaces < 0 {
+		invalid("tenants.maxNamespaces must not be negative")
+	}
+	if c.Tenants.MaxTodos < 0 {
+		invalid("tenants.maxTodos must not be negative")
+	}
+
+	for name, limit := range c.RateLimit.all() {
+		if limit.Rate < 0 {
+			invalid("%s rate must not be negative", name)
+		}
+		if limit.Rate > 0 && limit.Burst < 1 {
+			invalid("%s burst must be at least 1", name)
+		}
+	}
+
+	if !slices.Contains(authModes, c.Auth.Mode) {
+		invalid("auth.mode %q must be one of %s", c.Auth.Mode, strings.Join(authModes, ", "))
+	}
+	if c.Auth.Mode == AUTH_MODE_JWT && c.Auth.JWKSURL == "" && c.Auth.JWTKeyFile == "" && c.Auth.JWTSecret == "" {
+		invalid("auth.jwksUrl, auth.jwtKeyFile or auth.jwtSecret is required when auth.mode is %q", AUTH_MODE_JWT)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Write prints the configuration as YAML, in the same shape a config file uses.
+// Secrets are masked so the output can be shared
+func (c *Config) Write(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+
+	return enc.Encode(c.redacted())
+}
+
+// redacted returns a copy of the configuration with the secrets masked
+func (c *Config) redacted() *Config {
+	redacted := *c
+	redact(&redacted.Auth.AdminKey)
+	redact(&redacted.Auth.JWTSecret)
+	redacted.Storage.DSN = redactDSN(redacted.Storage.DSN)
+
+	return &redacted
+}
+
+func redact(secret *string) {
+	if *secret != "" {
+		*secret = REDACTED
+	}
+}
+
+// redactDSN masks the password of a URL or key=value connection string. Any
+// other DSN is masked as a whole, as its password cannot be told apart
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.Host != "" {
+		u.RawQuery = queryPassword.ReplaceAllString(u.RawQuery, "${1}"+REDACTED)
+		// Redacted masks the password as "xxxxx", which could be a real one
+		return strings.Replace(u.Redacted(), ":xxxxx@", ":"+REDACTED+"@", 1)
+	}
+	if keyValueDSN.MatchString(dsn) {
+		return dsnPassword.ReplaceAllString(dsn, "${1}"+REDACTED)
+	}
+
+	redact(&dsn)
+	return dsn
+}
+
+// Duration reads and writes time.Duration values like "200ms" in config files
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	val, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(val)
+	return nil
+}
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// Durations converts a map of Duration values to time.Duration values
+func Durations(m map[string]Duration) map[string]time.Duration {
+	durations := make(map[string]time.Duration, len(m))
+	for k, d := range m {
+		durations[k] = d.Duration()
+	}
+
+	return durations
+}
+
+// all returns the default limit and every route override, named as in errors
+func (c *RateLimitConfig) all() map[string]RateLimit {
+	limits := map[string]RateLimit{
+		"rateLimit":              {Rate: c.Rate, Burst: c.Burst},
+		"rateLimit.authFailures": c.AuthFailures,
+	}
+	for op, limit := range c.Routes {
+		limits[fmt.Sprintf("rateLimit.routes %q", op)] = limit
+	}
+
+	return limits
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			data: "http:\n  addr: :8081\ngrpc:\n  addr: :9091\nlog:\n  level: warn\n",
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			data: "[http]\naddr = \":8081\"\n[grpc]\naddr = \":9091\"\n[log]\nlevel = \"warn\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv(envName(CONFIG_FILE_FLAG), path)
+			t.Setenv("TODO_LOG_LEVEL", "error")
+			t.Setenv("TODO_GRPC_ADDR", ":9092")
+
+			cfg, _, err := Load([]string{"--grpc-addr", ":9093"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range []struct {
+				setting  string
+				expected string
+				actual   string
+			}{
+				{"tenants.maxTodos from the defaults", "1000", strconv.Itoa(cfg.Tenants.MaxTodos)},
+				{"http.addr from the file", ":8081", cfg.HTTP.Addr},
+				{"log.level from the environment", "error", cfg.Log.Level},
+				{"grpc.addr from the flags", ":9093", cfg.GRPC.Addr},
+			} {
+				if s.actual != s.expected {
+					t.Errorf("expected %s to be %q, got %q", s.setting, s.expected, s.actual)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		err  error
+	}{
+		{
+			name: "missing file",
+			args: []string{"--config", "missing.yaml"},
+			err:  os.ErrNotExist,
+		},
+		{
+			name: "malformed environment variable",
+			env:  map[string]string{"TODO_HTTP_REQUEST_TIMEOUT": "soon"},
+			err:  ErrInvalidConfig,
+		},
+		{
+			name: "malformed flag",
+			args: []string{"--rate-limit-routes", "getTodos=fast"},
+			err:  ErrInvalidConfig,
+		},
+		{
+			name: "invalid setting",
+			args: []string{"--log-level", "loud"},
+			err:  ErrInvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, _, err := Load(tt.args); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		errors []string
+	}{
+		{
+			name:   "defaults",
+			change: func(c *Config) {},
+		},
+		{
+			name: "every setting invalid at once",
+			change: func(c *Config) {
+				c.HTTP.Addr = "8080"
+				c.HTTP.RequestTimeout = 0
+				c.HTTP.RouteTimeouts = map[string]Duration{"getTodos": Duration(-time.Second)}
+				c.Log.Level = "loud"
+			},
+			errors: []string{"http.addr", "http.requestTimeout", `http.routeTimeouts "getTodos"`, "log.level"},
+		},
+		{
+			name: "negative duration",
+			change: func(c *Config) {
+				c.Health.CacheTTL = Duration(-time.Second)
+			},
+			errors: []string{"health.cacheTtl must not be negative"},
+		},
+		{
+			name: "certificate without its key",
+			change: func(c *Config) {
+				c.HTTP.TLS.CertFile = "cert.pem"
+			},
+			errors: []string{"http.tls.certFile and http.tls.keyFile must be set together"},
+		},
+		{
+			name: "redirect without https",
+			change: func(c *Config) {
+				c.HTTP.TLS.RedirectAddr = ":80"
+			},
+			errors: []string{"http.tls.redirectAddr requires"},
+		},
+		{
+			name: "credentials for every origin",
+			change: func(c *Config) {
+				c.CORS.AllowedOrigins = []string{"*"}
+				c.CORS.AllowCredentials = true
+			},
+			errors: []string{"cors.allowCredentials"},
+		},
+		{
+			name: "rate limit without a burst",
+			change: func(c *Config) {
+				c.RateLimit.Routes = map[string]RateLimit{"getTodos": {Rate: 1}}
+			},
+			errors: []string{`rateLimit.routes "getTodos" burst must be at least 1`},
+		},
+		{
+			name: "jwt without keys",
+			change: func(c *Config) {
+				c.Auth.Mode = AUTH_MODE_JWT
+			},
+			errors: []string{"auth.jwksUrl, auth.jwtKeyFile or auth.jwtSecret is required"},
+		},
+		{
+			name: "unknown backend",
+			change: func(c *Config) {
+				c.Storage.Backend = "postgres"
+			},
+			errors: []string{`storage.backend "postgres"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(cfg)
+
+			err := cfg.Validate()
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("expected %v, got %v", ErrInvalidConfig, err)
+			}
+			for _, msg := range tt.errors {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("expected the error to mention %q, got %q", msg, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteRedactsSecrets(t *testing.T) {
+	tests := []struct {
+		name     string
+		dsn      string
+		expected string
+	}{
+		{
+			name:     "url",
+			dsn:      "postgres://todo:secret@db:5432/todos?sslmode=disable",
+			expected: "postgres://todo:***@db:5432/todos?sslmode=disable",
+		},
+		{
+			name:     "url without a password",
+			dsn:      "postgres://todo@db:5432/todos",
+			expected: "postgres://todo@db:5432/todos",
+		},
+		{
+			name:     "url with the password in the query",
+			dsn:      "postgres://db/todos?user=todo&password=secret&sslmode=disable",
+			expected: "postgres://db/todos?user=todo&password=***&sslmode=disable",
+		},
+		{
+			name:     "key value pairs",
+			dsn:      "host=db user=todo password='se cret' dbname=todos",
+			expected: "host=db user=todo password=*** dbname=todos",
+		},
+		{
+			name:     "other format",
+			dsn:      "todo:secret@tcp(db:3306)/todos",
+			expected: "***",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Storage.DSN = tt.dsn
+			cfg.Auth.AdminKey = "todo_abc_secret"
+			cfg.Auth.JWTSecret = "secret"
+
+			if dsn := cfg.redacted().Storage.DSN; dsn != tt.expected {
+				t.Errorf("expected the dsn to be printed as %q, got %q", tt.expected, dsn)
+			}
+
+			var out bytes.Buffer
+			if err := cfg.Write(&out); err != nil {
+				t.Fatal(err)
+			}
+			if printed := out.String(); strings.Contains(printed, "secret") {
+				t.Errorf("expected every secret to be masked, got:\n%s", printed)
+			}
+			if cfg.Storage.DSN != tt.dsn || cfg.Auth.JWTSecret != "secret" {
+				t.Error("expected printing to leave the configuration unchanged")
+			}
+		})
+	}
+}
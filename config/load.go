@@ -0,0 +1,327 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ENV_PREFIX        = "TODO_"
+	CONFIG_FILE_FLAG  = "config"
+	PRINT_CONFIG_FLAG = "print-config"
+)
+
+var (
+	ErrUnknownFileType = fmt.Errorf("unknown config file type")
+)
+
+// setting is a single configuration value that can be provided by a flag or
+// an environment variable. The flag and variable names are derived from the
+// dotted name, so "http.requestTimeout" is set by --http-request-timeout and
+// TODO_HTTP_REQUEST_TIMEOUT
+type setting struct {
+	name  string
+	usage string
+	set   func(c *Config, val string) error
+}
+
+var settings = []setting{
+	{"http.addr", "address the REST API listens on", stringSetting(func(c *Config) *string { return &c.HTTP.Addr })},
+	{"http.requestTimeout", "time allowed for the repository to answer a request", durationSetting(func(c *Config) *Duration { return &c.HTTP.RequestTimeout })},
+	{"http.routeTimeouts", "comma separated operationId=duration pairs overriding the request timeout", durationMapSetting(func(c *Config) *map[string]Duration { return &c.HTTP.RouteTimeouts })},
+	{"http.readTimeout", "time allowed to read a whole request, 0 to disable", durationSetting(func(c *Config) *Duration { return &c.HTTP.ReadTimeout })},
+	{"http.writeTimeout", "time allowed to write a response, 0 to disable", durationSetting(func(c *Config) *Duration { return &c.HTTP.WriteTimeout })},
+	{"http.idleTimeout", "time to keep idle connections open", durationSetting(func(c *Config) *Duration { return &c.HTTP.IdleTimeout })},
+	{"http.shutdownDelay", "time to keep serving after reporting unavailable on shutdown, so load balancers can react", durationSetting(func(c *Config) *Duration { return &c.HTTP.ShutdownDelay })},
+	{"http.shutdownTimeout", "time allowed for in-flight requests to finish on shutdown", durationSetting(func(c *Config) *Duration { return &c.HTTP.ShutdownTimeout })},
+	{"http.validateResponses", "log responses that do not match the OpenAPI spec", boolSetting(func(c *Config) *bool { return &c.HTTP.ValidateResponses })},
+	{"http.tls.certFile", "PEM certificate chain to serve the REST API over HTTPS with", stringSetting(func(c *Config) *string { return &c.HTTP.TLS.CertFile })},
+	{"http.tls.keyFile", "PEM private key of the certificate", stringSetting(func(c *Config) *string { return &c.HTTP.TLS.KeyFile })},
+	{"http.tls.clientCaFile", "PEM CA bundle that clients must present a certificate signed by, except to the probes and metrics", stringSetting(func(c *Config) *string { return &c.HTTP.TLS.ClientCAFile })},
+	{"http.tls.reloadInterval", "how often to check the certificate files for changes, 0 to disable", durationSetting(func(c *Config) *Duration { return &c.HTTP.TLS.ReloadInterval })},
+	{"http.tls.redirectAddr", "address to redirect plain HTTP requests to HTTPS from", stringSetting(func(c *Config) *string { return &c.HTTP.TLS.RedirectAddr })},
+	{"grpc.addr", "address the gRPC API listens on", stringSetting(func(c *Config) *string { return &c.GRPC.Addr })},
+	{"storage.backend", "todo storage backend", stringSetting(func(c *Config) *string { return &c.Storage.Backend })},
+	{"storage.dsn", "connection string for the storage backend", stringSetting(func(c *Config) *string { return &c.Storage.DSN })},
+	{"health.cacheTtl", "how long health check results are cached, 0 to run the checks on every probe", durationSetting(func(c *Config) *Duration { return &c.Health.CacheTTL })},
+	{"health.checkTimeout", "time allowed for each health check", durationSetting(func(c *Config) *Duration { return &c.Health.CheckTimeout })},
+	{"tracing.exporter", "trace exporter: none, otlp or stdout", stringSetting(func(c *Config) *string { return &c.Tracing.Exporter })},
+	{"tracing.endpoint", "host:port of the OTLP/HTTP collector", stringSetting(func(c *Config) *string { return &c.Tracing.Endpoint })},
+	{"tracing.insecure", "send traces to the collector over plain HTTP", boolSetting(func(c *Config) *bool { return &c.Tracing.Insecure })},
+	{"tracing.sampleRatio", "fraction of new traces to record, between 0 and 1", floatSetting(func(c *Config) *float64 { return &c.Tracing.SampleRatio })},
+	{"log.level", "minimum log level: debug, info, warn or error", stringSetting(func(c *Config) *string { return &c.Log.Level })},
+	{"log.format", "log output format: text or json", stringSetting(func(c *Config) *string { return &c.Log.Format })},
+	{"cors.allowedOrigins", "comma separated origins allowed to call the API, * matches any", listSetting(func(c *Config) *[]string { return &c.CORS.AllowedOrigins })},
+	{"cors.allowedMethods", "comma separated methods allowed in cross-origin requests", listSetting(func(c *Config) *[]string { return &c.CORS.AllowedMethods })},
+	{"cors.allowedHeaders", "comma separated headers allowed in cross-origin requests", listSetting(func(c *Config) *[]string { return &c.CORS.AllowedHeaders })},
+	{"cors.allowCredentials", "allow cookies and credentials in cross-origin requests", boolSetting(func(c *Config) *bool { return &c.CORS.AllowCredentials })},
+	{"cors.maxAge", "how long browsers may cache preflight responses", durationSetting(func(c *Config) *Duration { return &c.CORS.MaxAge })},
+	{"auth.mode", "authentication mode: none, apikey or jwt", stringSetting(func(c *Config) *string { return &c.Auth.Mode })},
+	{"auth.adminKey", "API key with the admin scope, in the todo_<id>_<secret> format", stringSetting(func(c *Config) *string { return &c.Auth.AdminKey })},
+	{"auth.jwksUrl", "URL of the JSON web key set used to verify tokens", stringSetting(func(c *Config) *string { return &c.Auth.JWKSURL })},
+	{"auth.jwtKeyFile", "PEM public key or certificate used to verify tokens instead of the jwks url", stringSetting(func(c *Config) *string { return &c.Auth.JWTKeyFile })},
+	{"auth.jwtSecret", "HMAC secret used to verify tokens instead of the jwks url, for local testing", stringSetting(func(c *Config) *string { return &c.Auth.JWTSecret })},
+	{"auth.issuer", "required issuer of bearer tokens", stringSetting(func(c *Config) *string { return &c.Auth.Issuer })},
+	{"auth.audience", "required audience of bearer tokens", stringSetting(func(c *Config) *string { return &c.Auth.Audience })},
+	{"auth.scopeClaim", "token claim holding the granted scopes", stringSetting(func(c *Config) *string { return &c.Auth.ScopeClaim })},
+	{"rateLimit.rate", "requests per second each client may make to each operation, 0 to disable", floatSetting(func(c *Config) *float64 { return &c.RateLimit.Rate })},
+	{"rateLimit.burst", "requests each client may make to each operation in a burst", intSetting(func(c *Config) *int { return &c.RateLimit.Burst })},
+	{"rateLimit.routes", "comma separated operationId=rate/burst pairs overriding the rate limit", rateLimitMapSetting(func(c *Config) *map[string]RateLimit { return &c.RateLimit.Routes })},
+	{"rateLimit.authFailures.rate", "failed authentication attempts per second each IP address may make, 0 to disable", floatSetting(func(c *Config) *float64 { return &c.RateLimit.AuthFailures.Rate })},
+	{"rateLimit.authFailures.burst", "failed authentication attempts each IP address may make in a burst", intSetting(func(c *Config) *int { return &c.RateLimit.AuthFailures.Burst })},
+	{"tenants.maxNamespaces", "how many namespaces may be created, 0 for no limit", intSetting(func(c *Config) *int { return &c.Tenants.MaxNamespaces })},
+	{"tenants.maxTodos", "default todo quota of new namespaces, 0 for no limit", intSetting(func(c *Config) *int { return &c.Tenants.MaxTodos })},
+}
+
+// boolSettings can be passed as a bare flag, so --cors-allow-credentials means
+// --cors-allow-credentials=true
+var boolSettings = map[string]bool{
+	"cors.allowCredentials":  true,
+	"http.validateResponses": true,
+	"tracing.insecure":       true,
+}
+
+// Options are the command line switches that are not configuration values
+type Options struct {
+	PrintConfig bool
+}
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the config file, environment variables and command line flags.
+// The config file is named by --config or TODO_CONFIG and may be YAML or TOML
+func Load(args []string) (*Config, *Options, error) {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := fs.String(CONFIG_FILE_FLAG, os.Getenv(envName(CONFIG_FILE_FLAG)), "path to a YAML or TOML config file")
+	opts := &Options{}
+	fs.BoolVar(&opts.PrintConfig, PRINT_CONFIG_FLAG, false, "print the resolved configuration and exit")
+
+	// flags are only recorded here and applied last so they take precedence
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		name := s.name
+		record := func(val string) error {
+			flagValues[name] = val
+			return nil
+		}
+		if boolSettings[name] {
+			fs.BoolFunc(flagName(name), s.usage, record)
+		} else {
+			fs.Func(flagName(name), s.usage, record)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	config := Default()
+
+	if *configFile != "" {
+		if err := loadFile(config, *configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, s := range settings {
+		val, ok := os.LookupEnv(envName(s.name))
+		if !ok {
+			continue
+		}
+		if err := s.set(config, val); err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, envName(s.name), err)
+		}
+	}
+
+	for _, s := range settings {
+		val, ok := flagValues[s.name]
+		if !ok {
+			continue
+		}
+		if err := s.set(config, val); err != nil {
+			return nil, nil, fmt.Errorf("%w: --%s: %s", ErrInvalidConfig, flagName(s.name), err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return config, opts, nil
+}
+
+func loadFile(config *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, config)
+	case ".toml":
+		err = toml.Unmarshal(data, config)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFileType, path)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidConfig, path, err)
+	}
+
+	return nil
+}
+
+// flagName converts "http.requestTimeout" to "http-request-timeout"
+func flagName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '.':
+			b.WriteRune('-')
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune('-')
+			b.WriteRune(r + ('a' - 'A'))
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// envName converts "http.requestTimeout" to "TODO_HTTP_REQUEST_TIMEOUT"
+func envName(name string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(flagName(name), "-", "_"))
+}
+
+func stringSetting(field func(c *Config) *string) func(c *Config, val string) error {
+	return func(c *Config, val string) error {
+		*field(c) = val
+		return nil
+	}
+}
+
+func boolSetting(field func(c *Config) *bool) func(c *Config, val string) error {
+	return func(c *Config, val string) error {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+
+		*field(c) = b
+		return nil
+	}
+}
+
+func intSetting(field func(c *Config) *int) func(c *Config, val string) error {
+	return func(c *Config, val string) error {
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			return err
+		}
+
+		*field(c) = i
+		return nil
+	}
+}
+
+func floatSetting(field func(c *Config) *float64) func(c *Config, val string) error {
+	return func(c *Config, val string) error {
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return err
+		}
+
+		*field(c) = f
+		return nil
+	}
+}
+
+func durationSetting(field func(c *Config) *Duration) func(c *Config, val string) error {
+	return func(c *Config, val string) error {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+
+		*field(c) = Duration(d)
+		return nil
+	}
+}
+
+func listSetting(field func(c *Config) *[]string) func(c *Config, val string) error {
+	return func(c *Config, val string) error {
+		list := make([]string, 0)
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+
+		*field(c) = list
+		return nil
+	}
+}
+
+func durationMapSetting(field func(c *Config) *map[string]Duration) func(c *Config, val string) error {
+	return func(c *Config, val string) error {
+		m := make(map[string]Duration)
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+
+			key, dur, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("expected key=duration, got %q", item)
+			}
+			d, err := time.ParseDuration(strings.TrimSpace(dur))
+			if err != nil {
+				return err
+			}
+			m[strings.TrimSpace(key)] = Duration(d)
+		}
+
+		*field(c) = m
+		return nil
+	}
+}
+
+func rateLimitMapSetting(field func(c *Config) *map[string]RateLimit) func(c *Config, val string) error {
+	return func(c *Config, val string) error {
+		m := make(map[string]RateLimit)
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+
+			key, limit, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("expected key=rate/burst, got %q", item)
+			}
+			rateStr, burstStr, ok := strings.Cut(limit, "/")
+			if !ok {
+				return fmt.Errorf("expected key=rate/burst, got %q", item)
+			}
+			rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+			if err != nil {
+				return err
+			}
+			burst, err := strconv.Atoi(strings.TrimSpace(burstStr))
+			if err != nil {
+				return err
+			}
+			m[strings.TrimSpace(key)] = RateLimit{Rate: rate, Burst: burst}
+		}
+
+		*field(c) = m
+		return nil
+	}
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "context"
+
+type TodoEventType string
+
+const (
+	TodoCreated TodoEventType = "created"
+	TodoUpdated TodoEventType = "updated"
+	TodoDeleted TodoEventType = "deleted"
+)
+
+type TodoEvent struct {
+	Type TodoEventType `json:"type"`
+	Todo Todo          `json:"todo"`
+}
+
+type TodoEventSource interface {
+	Subscribe(ctx context.Context) <-chan TodoEvent
+}
@@ -0,0 +1,9 @@
+package domain
+
+import "context"
+
+// HealthChecker is implemented by dependencies that can report whether they
+// are able to serve requests
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
@@ -1,6 +1,30 @@
 package domain
 
+import "context"
+
+// Logger writes leveled, structured log entries. args are alternating keys
+// and values added to the entry as attributes
 type Logger interface {
-	Info(str string)
-	Error(str string)
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+	// With returns a child logger adding args to every entry it writes
+	With(args ...any) Logger
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying log, so code handling a
+// request can log with the request's attributes
+func ContextWithLogger(ctx context.Context, log Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// LoggerFromContext returns the logger on ctx, or fallback if there is none
+func LoggerFromContext(ctx context.Context, fallback Logger) Logger {
+	if log, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return log
+	}
+	return fallback
 }
@@ -0,0 +1,20 @@
+package domain
+
+import "context"
+
+// DEFAULT_NAMESPACE holds the todos of requests that do not select a namespace
+const DEFAULT_NAMESPACE = ""
+
+type namespaceKey struct{}
+
+// ContextWithNamespace returns a copy of ctx whose todos are kept in namespace
+func ContextWithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+// NamespaceFromContext returns the namespace selected for ctx, or
+// DEFAULT_NAMESPACE if there is none
+func NamespaceFromContext(ctx context.Context) string {
+	namespace, _ := ctx.Value(namespaceKey{}).(string)
+	return namespace
+}
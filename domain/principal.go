@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"slices"
+)
+
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Id     string
+	Scopes []Scope
+}
+
+// HasScope reports whether the principal was granted scope. Admin implies
+// every scope and write implies read
+func (p *Principal) HasScope(scope Scope) bool {
+	switch {
+	case slices.Contains(p.Scopes, ScopeAdmin):
+		return true
+	case scope == ScopeRead && slices.Contains(p.Scopes, ScopeWrite):
+		return true
+	default:
+		return slices.Contains(p.Scopes, scope)
+	}
+}
+
+type principalKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller of the request ctx belongs to, or
+// nil if it was not authenticated
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// SystemPrincipal is used by background work that reads across every owner,
+// such as collecting metrics
+var SystemPrincipal = &Principal{
+	Id:     "system",
+	Scopes: []Scope{ScopeAdmin},
+}
+
+// OwnerFromContext returns the owner of the todos created in ctx, and whether
+// the caller may also reach the todos of every other owner. Requests without
+// a principal, which only happen when authentication is disabled, share the
+// empty owner
+func OwnerFromContext(ctx context.Context) (ownerId string, all bool) {
+	principal := PrincipalFromContext(ctx)
+	if principal == nil {
+		return "", false
+	}
+
+	return principal.Id, principal.HasScope(ScopeAdmin)
+}
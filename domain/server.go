@@ -1,6 +1,8 @@
 package domain
 
+import "context"
+
 type Server interface {
-	Run()
-	Stop()
+	Run() error
+	Stop(ctx context.Context) error
 }
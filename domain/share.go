@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// Role is what a collaborator may do with the list of todos shared with them.
+// A list is identified by the id of the principal owning its todos
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+// roles is ordered from the least to the most privileged
+var roles = []Role{RoleViewer, RoleEditor, RoleOwner}
+
+func (r Role) Valid() bool {
+	return slices.Contains(roles, r)
+}
+
+// Allows reports whether r grants everything required does. Editors may also
+// view, and owners may also edit and manage the collaborators
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && slices.Index(roles, r) >= slices.Index(roles, required)
+}
+
+type Collaborator struct {
+	ListId  string    `json:"listId"`
+	UserId  string    `json:"userId"`
+	Role    Role      `json:"role"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// Invitation offers a role on a list to a user, who becomes a collaborator
+// by accepting it
+type Invitation struct {
+	Id        string    `json:"id"`
+	ListId    string    `json:"listId"`
+	UserId    string    `json:"userId"`
+	Role      Role      `json:"role"`
+	InvitedBy string    `json:"invitedBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SharedList is a list shared with the caller, with the todos it holds
+type SharedList struct {
+	ListId string `json:"listId"`
+	Role   Role   `json:"role"`
+	Todos  []Todo `json:"todos"`
+}
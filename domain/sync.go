@@ -0,0 +1,26 @@
+package domain
+
+import "fmt"
+
+var (
+	// ErrSyncExpired is returned for sync positions the change log can no
+	// longer answer, so clients have to fetch every todo again
+	ErrSyncExpired = fmt.Errorf("sync token expired, fetch every todo again")
+)
+
+// TodoChange is a single entry in the repository change log. Deleted changes
+// are tombstones and only carry the id of the removed todo
+type TodoChange struct {
+	Seq     uint64 `json:"seq"`
+	Deleted bool   `json:"deleted"`
+	Todo    Todo   `json:"todo"`
+}
+
+// SyncPosition is a position in the change log of a store. Every store has
+// its own epoch, so a position from a log that was lost, e.g. when the store
+// restarted or was reset, is never read as one in the current log. The zero
+// SyncPosition comes before every change
+type SyncPosition struct {
+	Epoch string
+	Seq   uint64
+}
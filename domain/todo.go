@@ -1,18 +1,76 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MAX_DESCRIPTION_LENGTH is the most characters a description may have
+	MAX_DESCRIPTION_LENGTH = 1000
+)
+
+var (
+	ErrEmptyDescription   = fmt.Errorf("description must not be empty")
+	ErrDescriptionTooLong = fmt.Errorf("description must be at most %d characters", MAX_DESCRIPTION_LENGTH)
+
+	// ErrTodoNotFound is returned for todos that do not exist or belong to
+	// another owner, so callers cannot learn which ids are taken
+	ErrTodoNotFound = fmt.Errorf("todo does not exist")
+	// ErrTodoChanged is returned by conditional writes to a todo that was
+	// changed after the sequence number they were based on
+	ErrTodoChanged = fmt.Errorf("todo has changed")
+)
 
 type Todo struct {
 	Id          string    `json:"id"`
+	OwnerId     string    `json:"ownerId"`
 	Done        bool      `json:"done"`
 	Description string    `json:"description"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
 	DoneAt      time.Time `json:"doneAt"`
+	Seq         uint64    `json:"seq"`
+}
+
+// ValidateDescription returns ErrEmptyDescription or ErrDescriptionTooLong
+// when a todo may not have description. Descriptions of only whitespace count
+// as empty
+func ValidateDescription(description string) error {
+	if strings.TrimSpace(description) == "" {
+		return ErrEmptyDescription
+	}
+	if utf8.RuneCountInString(description) > MAX_DESCRIPTION_LENGTH {
+		return ErrDescriptionTooLong
+	}
+	return nil
+}
+
+// TodoCursor is a position in the todos ordered by creation time, with their
+// ids breaking ties
+type TodoCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+func CursorOf(todo *Todo) TodoCursor {
+	return TodoCursor{CreatedAt: todo.CreatedAt, Id: todo.Id}
+}
+
+// Before reports whether the cursor comes before todo
+func (c TodoCursor) Before(todo *Todo) bool {
+	if c.CreatedAt.Equal(todo.CreatedAt) {
+		return c.Id < todo.Id
+	}
+	return c.CreatedAt.Before(todo.CreatedAt)
 }
 
 type NewTodo struct {
 	Description string `json:"description"`
+	Done        bool   `json:"done"`
 }
 
 type UpdateTodo struct {
@@ -20,10 +78,51 @@ type UpdateTodo struct {
 	Description string `json:"description"`
 }
 
+// TodoCount is how many todos there are, and how many of them are done
+type TodoCount struct {
+	Total int
+	Done  int
+}
+
+// TodoWrite is one write of a batch. It updates the todo with Id, or creates
+// a todo when Id is empty or no todo with Id can be reached
+type TodoWrite struct {
+	Id          string
+	Description string
+	Done        bool
+	// OwnerId owns a created todo when the principal of the batch may reach
+	// the todos of every owner, otherwise the principal owns it
+	OwnerId string
+}
+
+// TodoRepository stores the todos of every owner. Each method only reaches
+// the todos owned by the principal of ctx, treating any other todo as missing,
+// unless the principal has the admin scope. Writes fail with the error of
+// ValidateDescription when a description is not valid
 type TodoRepository interface {
-	CreateTodo(newTodo *NewTodo) (*Todo, error)
-	GetTodo(id string) (*Todo, error)
-	GetTodos() (*[]Todo, error)
-	UpdateTodo(id string, todo *UpdateTodo) (*Todo, error)
-	DeleteTodo(id string) error
+	CreateTodo(ctx context.Context, newTodo *NewTodo) (*Todo, error)
+	// CreateTodos creates every todo in a single batch, or none of them
+	CreateTodos(ctx context.Context, newTodos *[]NewTodo) (*[]Todo, error)
+	// SaveTodos applies every write in a single batch, or none of them,
+	// returning the written todos in the order of their writes
+	SaveTodos(ctx context.Context, writes *[]TodoWrite) (*[]Todo, error)
+	GetTodo(ctx context.Context, id string) (*Todo, error)
+	GetTodos(ctx context.Context) (*[]Todo, error)
+	// CountTodos counts the todos GetTodos would return
+	CountTodos(ctx context.Context) (*TodoCount, error)
+	// GetTodosPage returns up to limit todos ordered by creation time,
+	// starting after the cursor, or with the first todo when it is nil
+	GetTodosPage(ctx context.Context, after *TodoCursor, limit int) (*[]Todo, error)
+	UpdateTodo(ctx context.Context, id string, todo *UpdateTodo) (*Todo, error)
+	// DeleteTodo returns ErrTodoNotFound when there was no todo to remove
+	DeleteTodo(ctx context.Context, id string) error
+	// UpdateTodoIfUnchanged and DeleteTodoIfUnchanged only write the todo if
+	// its sequence number is still seq, returning ErrTodoChanged otherwise
+	UpdateTodoIfUnchanged(ctx context.Context, id string, seq uint64, todo *UpdateTodo) (*Todo, error)
+	DeleteTodoIfUnchanged(ctx context.Context, id string, seq uint64) error
+	// GetChanges returns every change recorded after since, ordered by
+	// sequence number, along with the position of the latest change. It
+	// returns ErrSyncExpired when since is not a position in the current
+	// change log, or the changes after it are no longer all recorded
+	GetChanges(ctx context.Context, since SyncPosition) (*[]TodoChange, SyncPosition, error)
 }
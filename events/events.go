@@ -0,0 +1,176 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+const (
+	SUBSCRIBER_BUFFER = 16
+)
+
+// New wraps repo so every successful write is published to the subscribers
+// of the returned repository
+func New(repo domain.TodoRepository, log domain.Logger) *PublishingTodoRepository {
+	return &PublishingTodoRepository{
+		TodoRepository: repo,
+		subscribers:    make(map[chan domain.TodoEvent]subscriber),
+		log:            log,
+	}
+}
+
+type PublishingTodoRepository struct {
+	domain.TodoRepository
+	mu          sync.Mutex
+	subscribers map[chan domain.TodoEvent]subscriber
+	log         domain.Logger
+}
+
+// subscriber is who the changes sent to a channel may be shown to
+type subscriber struct {
+	namespace string
+	ownerId   string
+	all       bool
+}
+
+func (r *PublishingTodoRepository) CreateTodo(ctx context.Context, newTodo *domain.NewTodo) (*domain.Todo, error) {
+	todo, err := r.TodoRepository.CreateTodo(ctx, newTodo)
+	if err != nil {
+		return nil, err
+	}
+
+	r.publish(ctx, domain.TodoCreated, todo)
+	return todo, nil
+}
+
+func (r *PublishingTodoRepository) CreateTodos(ctx context.Context, newTodos *[]domain.NewTodo) (*[]domain.Todo, error) {
+	todos, err := r.TodoRepository.CreateTodos(ctx, newTodos)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, todo := range *todos {
+		r.publish(ctx, domain.TodoCreated, &todo)
+	}
+	return todos, nil
+}
+
+func (r *PublishingTodoRepository) SaveTodos(ctx context.Context, writes *[]domain.TodoWrite) (*[]domain.Todo, error) {
+	todos, err := r.TodoRepository.SaveTodos(ctx, writes)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, todo := range *todos {
+		// writes that could not reach their todo created a new one
+		if id := (*writes)[i].Id; id != "" && id == todo.Id {
+			r.publish(ctx, domain.TodoUpdated, &todo)
+		} else {
+			r.publish(ctx, domain.TodoCreated, &todo)
+		}
+	}
+	return todos, nil
+}
+
+func (r *PublishingTodoRepository) UpdateTodo(ctx context.Context, id string, update *domain.UpdateTodo) (*domain.Todo, error) {
+	todo, err := r.TodoRepository.UpdateTodo(ctx, id, update)
+	if err != nil {
+		return nil, err
+	}
+
+	r.publish(ctx, domain.TodoUpdated, todo)
+	return todo, nil
+}
+
+func (r *PublishingTodoRepository) DeleteTodo(ctx context.Context, id string) error {
+	// grab the todo first so subscribers know what was removed
+	todo, err := r.TodoRepository.GetTodo(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// the todo is only published if this call removed it, not a concurrent one
+	if err := r.TodoRepository.DeleteTodo(ctx, id); err != nil {
+		return err
+	}
+
+	r.publish(ctx, domain.TodoDeleted, todo)
+	return nil
+}
+
+func (r *PublishingTodoRepository) UpdateTodoIfUnchanged(ctx context.Context, id string, seq uint64, update *domain.UpdateTodo) (*domain.Todo, error) {
+	todo, err := r.TodoRepository.UpdateTodoIfUnchanged(ctx, id, seq, update)
+	if err != nil {
+		return nil, err
+	}
+
+	r.publish(ctx, domain.TodoUpdated, todo)
+	return todo, nil
+}
+
+func (r *PublishingTodoRepository) DeleteTodoIfUnchanged(ctx context.Context, id string, seq uint64) error {
+	todo, err := r.TodoRepository.GetTodo(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := r.TodoRepository.DeleteTodoIfUnchanged(ctx, id, seq); err != nil {
+		return err
+	}
+
+	r.publish(ctx, domain.TodoDeleted, todo)
+	return nil
+}
+
+// Subscribe returns a channel receiving every change to the todos the
+// principal of ctx may reach in its namespace until ctx is done
+func (r *PublishingTodoRepository) Subscribe(ctx context.Context) <-chan domain.TodoEvent {
+	ch := make(chan domain.TodoEvent, SUBSCRIBER_BUFFER)
+
+	ownerId, all := domain.OwnerFromContext(ctx)
+
+	r.mu.Lock()
+	r.subscribers[ch] = subscriber{
+		namespace: domain.NamespaceFromContext(ctx),
+		ownerId:   ownerId,
+		all:       all,
+	}
+	r.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		r.mu.Lock()
+		delete(r.subscribers, ch)
+		close(ch)
+		r.mu.Unlock()
+	}()
+
+	return ch
+}
+
+func (r *PublishingTodoRepository) publish(ctx context.Context, eventType domain.TodoEventType, todo *domain.Todo) {
+	namespace := domain.NamespaceFromContext(ctx)
+	event := domain.TodoEvent{
+		Type: eventType,
+		Todo: *todo,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for ch, sub := range r.subscribers {
+		if sub.namespace != namespace || (!sub.all && sub.ownerId != todo.OwnerId) {
+			continue
+		}
+
+		select {
+		case ch <- event:
+		default:
+			// never let a slow subscriber block a write
+			r.log.Warn("dropped todo event for slow subscriber", "event", eventType, "todoId", todo.Id)
+		}
+	}
+}
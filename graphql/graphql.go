@@ -0,0 +1,188 @@
+package graphql
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
+)
+
+var (
+	ErrInvalidRepo    = fmt.Errorf("invalid todo repository")
+	ErrInvalidEvents  = fmt.Errorf("invalid todo event source")
+	ErrInvalidLogger  = fmt.Errorf("invalid logger")
+	ErrNoQuery        = fmt.Errorf("no query provided")
+	ErrNoStreaming    = fmt.Errorf("streaming is not supported")
+	ErrInvalidRequest = fmt.Errorf("invalid graphql request")
+	// ErrMutationsOverGET means the schema root changed and readOnlySchema no
+	// longer removes the mutation root from it
+	ErrMutationsOverGET = fmt.Errorf("read only schema still offers mutations")
+)
+
+//go:embed schema.graphql
+var schema string
+
+// readOnlySchema drops the mutation root from schema. GET requests run against
+// it, so a link or image tag on another site cannot change any todos
+var readOnlySchema = strings.Replace(schema, "  mutation: Mutation\n", "", 1)
+
+type GraphQLConfig struct {
+	Repo   domain.TodoRepository
+	Events domain.TodoEventSource
+	Log    domain.Logger
+}
+
+func NewHandler(config *GraphQLConfig) (*Handler, error) {
+	if config.Repo == nil {
+		return nil, ErrInvalidRepo
+	}
+	if config.Events == nil {
+		return nil, ErrInvalidEvents
+	}
+	if config.Log == nil {
+		return nil, ErrInvalidLogger
+	}
+
+	resolver := &rootResolver{
+		repo:   config.Repo,
+		events: config.Events,
+		log:    config.Log,
+	}
+	s, err := graphql.ParseSchema(schema, resolver)
+	if err != nil {
+		return nil, err
+	}
+	readOnly, err := graphql.ParseSchema(readOnlySchema, resolver)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := readOnly.ASTSchema().EntryPoints["mutation"]; ok {
+		return nil, ErrMutationsOverGET
+	}
+
+	return &Handler{
+		schema:   s,
+		readOnly: readOnly,
+		log:      config.Log,
+	}, nil
+}
+
+type Handler struct {
+	schema *graphql.Schema
+	// readOnly serves GET requests, which may not run mutations
+	readOnly *graphql.Schema
+	log      domain.Logger
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeRequest(r)
+	if err != nil {
+		h.requestError(w, r, err)
+		return
+	}
+
+	s := h.schema
+	if r.Method != http.MethodPost {
+		s = h.readOnly
+	}
+
+	if Streaming(r) {
+		h.stream(w, r, s, req)
+		return
+	}
+
+	resp := s.Exec(r.Context(), req.Query, req.OperationName, req.Variables)
+
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Streaming reports whether r asks for its results over server-sent events,
+// which is how subscriptions are delivered. Those responses last until the
+// client goes away
+func Streaming(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request, s *graphql.Schema, req *request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.requestError(w, r, ErrNoStreaming)
+		return
+	}
+
+	responses, err := s.Subscribe(r.Context(), req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		h.requestError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/event-stream")
+	w.Header().Add("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for resp := range responses {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			domain.LoggerFromContext(r.Context(), h.log).Error(err.Error())
+			continue
+		}
+
+		fmt.Fprintf(w, "event: next\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	fmt.Fprint(w, "event: complete\ndata:\n\n")
+	flusher.Flush()
+}
+
+func (h *Handler) requestError(w http.ResponseWriter, r *http.Request, err error) {
+	domain.LoggerFromContext(r.Context(), h.log).Warn(err.Error())
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(graphql.Response{
+		Errors: []*errors.QueryError{errors.Errorf("%s", err)},
+	})
+}
+
+func decodeRequest(r *http.Request) (*request, error) {
+	var req request
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if vars := query.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				return nil, ErrInvalidRequest
+			}
+		}
+	case http.MethodPost:
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, ErrInvalidRequest
+		}
+	default:
+		return nil, ErrInvalidRequest
+	}
+
+	if req.Query == "" {
+		return nil, ErrNoQuery
+	}
+
+	return &req, nil
+}
@@ -0,0 +1,269 @@
+package graphql
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/events"
+	"github.com/brendenehlers/todo-microservice/memory"
+	"github.com/brendenehlers/todo-microservice/slogger"
+)
+
+func TestQueries(t *testing.T) {
+	handler, _ := newTestHandler(t, nil)
+
+	var created struct {
+		CreateTodo struct{ Id string }
+	}
+	for _, description := range []string{"write tests", "write docs"} {
+		post(t, handler, `mutation($d: String!) { createTodo(input: {description: $d}) { id } }`, map[string]any{"d": description}, &created)
+	}
+	post(t, handler, `mutation($id: ID!) { updateTodo(id: $id, input: {done: true}) { id } }`, map[string]any{"id": created.CreateTodo.Id}, nil)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "todo",
+			query:    `{ todo(id: "` + created.CreateTodo.Id + `") { description done } }`,
+			expected: `{"todo":{"description":"write docs","done":true}}`,
+		},
+		{
+			name:     "todos",
+			query:    `{ todos { totalCount items { description } } }`,
+			expected: `{"todos":{"totalCount":2,"items":[{"description":"write tests"},{"description":"write docs"}]}}`,
+		},
+		{
+			name:     "filter",
+			query:    `{ todos(filter: {done: false}) { totalCount items { description } } }`,
+			expected: `{"todos":{"totalCount":1,"items":[{"description":"write tests"}]}}`,
+		},
+		{
+			name:     "page",
+			query:    `{ todos(first: 1, offset: 1) { hasNextPage items { description } } }`,
+			expected: `{"todos":{"hasNextPage":false,"items":[{"description":"write docs"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data json.RawMessage
+			post(t, handler, tt.query, nil, &data)
+			if string(data) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, data)
+			}
+		})
+	}
+
+	var errs []struct{ Message string }
+	resp := exec(t, handler, http.MethodPost, `{ todo(id: "`+created.CreateTodo.Id+`0") { id } }`, nil)
+	if err := json.Unmarshal(resp["errors"], &errs); err != nil || len(errs) == 0 || errs[0].Message != domain.ErrTodoNotFound.Error() {
+		t.Errorf("expected %q for an unknown todo, got %s", domain.ErrTodoNotFound, resp["errors"])
+	}
+}
+
+func TestMutationsOverGET(t *testing.T) {
+	handler, repo := newTestHandler(t, nil)
+
+	resp := exec(t, handler, http.MethodGet, `mutation { createTodo(input: {description: "from a link"}) { id } }`, nil)
+	if len(resp["errors"]) == 0 {
+		t.Errorf("expected the mutation to be rejected, got %s", resp["data"])
+	}
+	todos, err := repo.GetTodos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*todos) != 0 {
+		t.Errorf("expected no todo to be created, got %d", len(*todos))
+	}
+
+	resp = exec(t, handler, http.MethodGet, `{ status }`, nil)
+	if string(resp["data"]) != `{"status":"ok"}` {
+		t.Errorf("expected queries to run over GET, got %s %s", resp["data"], resp["errors"])
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	handler, _ := newTestHandler(t, nil)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(`{"query": "subscription { todoChanged { type todo { description } } }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+
+	post(t, handler, `mutation { createTodo(input: {description: "streamed"}) { id } }`, nil, nil)
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		expected := `{"data":{"todoChanged":{"type":"CREATED","todo":{"description":"streamed"}}}}`
+		if data != expected {
+			t.Errorf("expected %s, got %s", expected, data)
+		}
+		return
+	}
+	t.Fatalf("expected an event before the stream ended: %v", lines.Err())
+}
+
+func TestUpdateTodoConcurrentChange(t *testing.T) {
+	var race func(ctx context.Context, id string)
+	handler, repo := newTestHandler(t, func(repo domain.TodoRepository) domain.TodoRepository {
+		return &racingRepository{TodoRepository: repo, race: func(ctx context.Context, id string) {
+			if race != nil {
+				race(ctx, id)
+			}
+		}}
+	})
+	todo, err := repo.CreateTodo(context.Background(), &domain.NewTodo{Description: "write tests"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := `mutation($id: ID!) { updateTodo(id: $id, input: {done: true}) { description done } }`
+
+	// another client changes the description between the read and the write,
+	// which is kept rather than overwritten with the value read
+	race = func(ctx context.Context, id string) {
+		race = nil
+		if _, err := repo.UpdateTodo(ctx, id, &domain.UpdateTodo{Description: "changed elsewhere"}); err != nil {
+			t.Error(err)
+		}
+	}
+	var data json.RawMessage
+	post(t, handler, update, map[string]any{"id": todo.Id}, &data)
+	if expected := `{"updateTodo":{"description":"changed elsewhere","done":true}}`; string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	// a todo that never stops changing fails after the last attempt
+	attempts := 0
+	race = func(ctx context.Context, id string) {
+		attempts++
+		if _, err := repo.UpdateTodo(ctx, id, &domain.UpdateTodo{Description: "changed again"}); err != nil {
+			t.Error(err)
+		}
+	}
+	resp := exec(t, handler, http.MethodPost, update, map[string]any{"id": todo.Id})
+	if !strings.Contains(string(resp["errors"]), domain.ErrTodoChanged.Error()) {
+		t.Errorf("expected %q, got %s", domain.ErrTodoChanged, resp["errors"])
+	}
+	if attempts != UPDATE_ATTEMPTS {
+		t.Errorf("expected %d attempts, got %d", UPDATE_ATTEMPTS, attempts)
+	}
+}
+
+func TestGraphiQL(t *testing.T) {
+	rec := httptest.NewRecorder()
+	GraphiQL().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphiql", nil))
+
+	page := rec.Body.String()
+	if !strings.Contains(page, "todoChanged: TodoChange!") {
+		t.Error("expected the schema to be rendered into the page")
+	}
+	// nothing is loaded from another origin, so the page works offline
+	if external := regexp.MustCompile(`(src|href)="(https?:)?//`).FindString(page); external != "" {
+		t.Errorf("expected no external assets, found %s", external)
+	}
+}
+
+// racingRepository runs race before every conditional update, standing in for
+// another client writing the todo at the same time
+type racingRepository struct {
+	domain.TodoRepository
+	race func(ctx context.Context, id string)
+}
+
+func (r *racingRepository) UpdateTodoIfUnchanged(ctx context.Context, id string, seq uint64, todo *domain.UpdateTodo) (*domain.Todo, error) {
+	r.race(ctx, id)
+	return r.TodoRepository.UpdateTodoIfUnchanged(ctx, id, seq, todo)
+}
+
+// newTestHandler serves an in-memory repository, passed through wrap when it
+// is set, and returns the repository the handler uses
+func newTestHandler(t *testing.T, wrap func(domain.TodoRepository) domain.TodoRepository) (*Handler, domain.TodoRepository) {
+	t.Helper()
+
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var repo domain.TodoRepository = memory.New(log)
+	if wrap != nil {
+		repo = wrap(repo)
+	}
+	published := events.New(repo, log)
+	handler, err := NewHandler(&GraphQLConfig{Repo: published, Events: published, Log: log})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return handler, published
+}
+
+// exec runs query and returns the top level fields of the response
+func exec(t *testing.T, handler http.Handler, method string, query string, variables map[string]any) map[string]json.RawMessage {
+	t.Helper()
+
+	var req *http.Request
+	if method == http.MethodGet {
+		req = httptest.NewRequest(method, "/graphql?query="+url.QueryEscape(query), nil)
+	} else {
+		body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = httptest.NewRequest(method, "/graphql", strings.NewReader(string(body)))
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp map[string]json.RawMessage
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// post runs query as a POST, failing on any error, and decodes its data into v
+// when v is not nil
+func post(t *testing.T, handler http.Handler, query string, variables map[string]any, v any) {
+	t.Helper()
+
+	resp := exec(t, handler, http.MethodPost, query, variables)
+	if len(resp["errors"]) > 0 {
+		t.Fatalf("unexpected errors: %s", resp["errors"])
+	}
+	if v != nil {
+		if err := json.Unmarshal(resp["data"], v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
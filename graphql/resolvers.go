@@ -0,0 +1,298 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// UPDATE_ATTEMPTS is how often a partial update is tried against a todo
+	// that keeps being changed by others before it fails with ErrTodoChanged
+	UPDATE_ATTEMPTS = 3
+)
+
+var (
+	ErrInvalidPagination = fmt.Errorf("first and offset must not be negative")
+	ErrForbidden         = fmt.Errorf("missing required scope")
+)
+
+type rootResolver struct {
+	repo   domain.TodoRepository
+	events domain.TodoEventSource
+	log    domain.Logger
+}
+
+func (*rootResolver) Status() string {
+	return "ok"
+}
+
+func (r *rootResolver) Todo(ctx context.Context, args struct{ Id graphql.ID }) (*todoResolver, error) {
+	todo, err := r.repo.GetTodo(ctx, string(args.Id))
+	if err != nil {
+		return nil, r.requestError(ctx, err)
+	}
+
+	r.logger(ctx).Info("Successfully found todo")
+	return &todoResolver{todo: *todo}, nil
+}
+
+type todoFilter struct {
+	Done                *bool
+	DescriptionContains *string
+}
+
+func (f *todoFilter) matches(todo *domain.Todo) bool {
+	if f == nil {
+		return true
+	}
+	if f.Done != nil && *f.Done != todo.Done {
+		return false
+	}
+	if f.DescriptionContains != nil && !strings.Contains(strings.ToLower(todo.Description), strings.ToLower(*f.DescriptionContains)) {
+		return false
+	}
+
+	return true
+}
+
+func (r *rootResolver) Todos(ctx context.Context, args struct {
+	Filter *todoFilter
+	First  *int32
+	Offset *int32
+}) (*todoConnectionResolver, error) {
+	todos, err := r.repo.GetTodos(ctx)
+	if err != nil {
+		return nil, r.requestError(ctx, err)
+	}
+
+	matched := make([]domain.Todo, 0)
+	for _, todo := range *todos {
+		if args.Filter.matches(&todo) {
+			matched = append(matched, todo)
+		}
+	}
+
+	// the repository makes no ordering guarantees, so sort to keep pages stable
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].Id < matched[j].Id
+		}
+		return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+	})
+
+	start, end := 0, len(matched)
+	if args.Offset != nil {
+		if *args.Offset < 0 {
+			return nil, ErrInvalidPagination
+		}
+		start = min(int(*args.Offset), end)
+	}
+	if args.First != nil {
+		if *args.First < 0 {
+			return nil, ErrInvalidPagination
+		}
+		end = min(start+int(*args.First), end)
+	}
+
+	r.logger(ctx).Info("Successfully retrieved todos")
+	return &todoConnectionResolver{
+		items:       matched[start:end],
+		totalCount:  len(matched),
+		hasNextPage: end < len(matched),
+	}, nil
+}
+
+func (r *rootResolver) CreateTodo(ctx context.Context, args struct {
+	Input struct{ Description string }
+}) (*todoResolver, error) {
+	if err := r.requireScope(ctx, domain.ScopeWrite); err != nil {
+		return nil, err
+	}
+
+	todo, err := r.repo.CreateTodo(ctx, &domain.NewTodo{
+		Description: args.Input.Description,
+	})
+	if err != nil {
+		return nil, r.requestError(ctx, err)
+	}
+
+	r.logger(ctx).Info("Successfully created todo")
+	return &todoResolver{todo: *todo}, nil
+}
+
+func (r *rootResolver) UpdateTodo(ctx context.Context, args struct {
+	Id    graphql.ID
+	Input struct {
+		Done        *bool
+		Description *string
+	}
+}) (*todoResolver, error) {
+	if err := r.requireScope(ctx, domain.ScopeWrite); err != nil {
+		return nil, err
+	}
+
+	id := string(args.Id)
+
+	// the fields left out of the input keep the values read here, so the
+	// write only succeeds if the todo has not changed since, and is merged
+	// with the new values again when it has
+	for attempt := 1; ; attempt++ {
+		current, err := r.repo.GetTodo(ctx, id)
+		if err != nil {
+			return nil, r.requestError(ctx, err)
+		}
+
+		update := &domain.UpdateTodo{
+			Done:        current.Done,
+			Description: current.Description,
+		}
+		if args.Input.Done != nil {
+			update.Done = *args.Input.Done
+		}
+		if args.Input.Description != nil {
+			update.Description = *args.Input.Description
+		}
+
+		todo, err := r.repo.UpdateTodoIfUnchanged(ctx, id, current.Seq, update)
+		switch {
+		case errors.Is(err, domain.ErrTodoChanged) && attempt < UPDATE_ATTEMPTS:
+			continue
+		case err != nil:
+			return nil, r.requestError(ctx, err)
+		}
+
+		r.logger(ctx).Info("Successfully updated todo")
+		return &todoResolver{todo: *todo}, nil
+	}
+}
+
+func (r *rootResolver) DeleteTodo(ctx context.Context, args struct{ Id graphql.ID }) (graphql.ID, error) {
+	if err := r.requireScope(ctx, domain.ScopeWrite); err != nil {
+		return "", err
+	}
+
+	err := r.repo.DeleteTodo(ctx, string(args.Id))
+	if err != nil {
+		return "", r.requestError(ctx, err)
+	}
+
+	r.logger(ctx).Info("Successfully deleted todo")
+	return args.Id, nil
+}
+
+// requireScope rejects callers without scope. Requests without a principal
+// are only possible when authentication is disabled, so they are let through
+func (r *rootResolver) requireScope(ctx context.Context, scope domain.Scope) error {
+	principal := domain.PrincipalFromContext(ctx)
+	if principal != nil && !principal.HasScope(scope) {
+		r.logger(ctx).Warn(ErrForbidden.Error(), "principal", principal.Id)
+		return ErrForbidden
+	}
+	return nil
+}
+
+// requestError logs err at a level matching its cause, so todos that do not
+// exist are not reported as failures of the service
+func (r *rootResolver) requestError(ctx context.Context, err error) error {
+	if errors.Is(err, domain.ErrTodoNotFound) || errors.Is(err, domain.ErrTodoChanged) || errors.Is(err, domain.ErrEmptyDescription) || errors.Is(err, domain.ErrDescriptionTooLong) {
+		r.logger(ctx).Info(err.Error())
+	} else {
+		r.logger(ctx).Error(err.Error())
+	}
+	return err
+}
+
+func (r *rootResolver) logger(ctx context.Context) domain.Logger {
+	return domain.LoggerFromContext(ctx, r.log)
+}
+
+func (r *rootResolver) TodoChanged(ctx context.Context) <-chan *todoChangeResolver {
+	events := r.events.Subscribe(ctx)
+	changes := make(chan *todoChangeResolver)
+
+	go func() {
+		defer close(changes)
+		for event := range events {
+			select {
+			case changes <- &todoChangeResolver{event: event}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes
+}
+
+type todoResolver struct {
+	todo domain.Todo
+}
+
+func (r *todoResolver) Id() graphql.ID {
+	return graphql.ID(r.todo.Id)
+}
+
+func (r *todoResolver) OwnerId() string {
+	return r.todo.OwnerId
+}
+
+func (r *todoResolver) Done() bool {
+	return r.todo.Done
+}
+
+func (r *todoResolver) Description() string {
+	return r.todo.Description
+}
+
+func (r *todoResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.todo.CreatedAt}
+}
+
+func (r *todoResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.todo.UpdatedAt}
+}
+
+func (r *todoResolver) DoneAt() graphql.Time {
+	return graphql.Time{Time: r.todo.DoneAt}
+}
+
+type todoConnectionResolver struct {
+	items       []domain.Todo
+	totalCount  int
+	hasNextPage bool
+}
+
+func (r *todoConnectionResolver) Items() []*todoResolver {
+	resolvers := make([]*todoResolver, 0, len(r.items))
+	for _, todo := range r.items {
+		resolvers = append(resolvers, &todoResolver{todo: todo})
+	}
+
+	return resolvers
+}
+
+func (r *todoConnectionResolver) TotalCount() int32 {
+	return int32(r.totalCount)
+}
+
+func (r *todoConnectionResolver) HasNextPage() bool {
+	return r.hasNextPage
+}
+
+type todoChangeResolver struct {
+	event domain.TodoEvent
+}
+
+func (r *todoChangeResolver) Type() string {
+	return strings.ToUpper(string(r.event.Type))
+}
+
+func (r *todoChangeResolver) Todo() *todoResolver {
+	return &todoResolver{todo: r.event.Todo}
+}
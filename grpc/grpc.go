@@ -0,0 +1,134 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/brendenehlers/todo-microservice/auth"
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/grpc/generated"
+	"github.com/brendenehlers/todo-microservice/health"
+	"github.com/brendenehlers/todo-microservice/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+const (
+	DEFAULT_ADDRESS = ":9090"
+)
+
+var (
+	ErrInvalidRepo   = fmt.Errorf("invalid todo repository")
+	ErrInvalidLogger = fmt.Errorf("invalid logger")
+	ErrForbidden     = fmt.Errorf("missing required scope")
+)
+
+type GRPCServerConfig struct {
+	Addr string
+	Repo domain.TodoRepository
+	Log  domain.Logger
+	// Health holds the dependency checks run by GetStatus
+	Health *health.Registry
+	// Auth authenticates callers of the methods that require a scope, every
+	// method is public when it is nil
+	Auth auth.Authenticator
+	// Namespaces lets calls select a namespace, Repo must be backed by it
+	Namespaces *tenant.Registry
+}
+
+func CreateGRPCServer(config *GRPCServerConfig) (*GrpcServer, error) {
+	if config.Repo == nil {
+		return nil, ErrInvalidRepo
+	}
+	if config.Log == nil {
+		return nil, ErrInvalidLogger
+	}
+
+	if config.Addr == "" {
+		config.Addr = DEFAULT_ADDRESS
+	}
+	if config.Health == nil {
+		config.Health = health.New(health.DEFAULT_CACHE_TTL, health.DEFAULT_CHECK_TIMEOUT)
+	}
+
+	interceptors := []grpc.UnaryServerInterceptor{
+		requestIDInterceptor,
+		loggingInterceptor(config.Log),
+	}
+	// callers are authenticated before their namespace is looked up, so
+	// unauthenticated callers cannot learn which namespaces exist
+	if config.Auth != nil {
+		interceptors = append(interceptors, authInterceptor(config.Auth, config.Log))
+	}
+	if config.Namespaces != nil {
+		interceptors = append(interceptors, namespaceInterceptor(config.Namespaces, config.Log))
+	}
+
+	ready := &atomic.Bool{}
+
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	generated.RegisterTodoServiceServer(s, newTodoService(config.Repo, config.Log, ready, config.Health))
+	reflection.Register(s)
+
+	server := &GrpcServer{
+		Server: s,
+		Addr:   config.Addr,
+		log:    config.Log,
+		ready:  ready,
+	}
+
+	return server, nil
+}
+
+type GrpcServer struct {
+	*grpc.Server
+	Addr  string
+	log   domain.Logger
+	ready *atomic.Bool
+}
+
+// Run blocks until the server stops, returning nil when it was stopped by Stop
+func (s *GrpcServer) Run() error {
+	lis, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("gRPC server running", "addr", s.Addr)
+	s.SetReady(true)
+
+	err = s.Serve(lis)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// SetReady controls whether GetStatus reports the server as able to take traffic
+func (s *GrpcServer) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// Stop waits for in-flight calls to finish, cancelling any still running
+// when ctx is done
+func (s *GrpcServer) Stop(ctx context.Context) error {
+	s.SetReady(false)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-ctx.Done():
+		s.log.Warn("shutdown grace period expired, cancelling open calls")
+		s.Server.Stop()
+	case <-stopped:
+	}
+
+	return nil
+}
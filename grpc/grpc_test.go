@@ -0,0 +1,283 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/brendenehlers/todo-microservice/auth"
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/grpc/generated"
+	"github.com/brendenehlers/todo-microservice/memory"
+	"github.com/brendenehlers/todo-microservice/slogger"
+	"github.com/brendenehlers/todo-microservice/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	keys := auth.NewKeyStore()
+	client := newTestClient(t, &GRPCServerConfig{Auth: keys})
+
+	read := issueTestKey(t, keys, domain.ScopeRead)
+	write := issueTestKey(t, keys, domain.ScopeWrite)
+	revoked, revokedKey, err := keys.Issue("revoked", []domain.Scope{domain.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Revoke(revokedKey.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	getTodos := func(ctx context.Context) error {
+		_, err := client.GetTodos(ctx, &generated.GetTodosRequest{})
+		return err
+	}
+	createTodo := func(ctx context.Context) error {
+		_, err := client.CreateTodo(ctx, &generated.CreateTodoRequest{Description: "write tests"})
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		call     func(ctx context.Context) error
+		metadata []string
+		code     codes.Code
+	}{
+		{
+			name: "public method without a key",
+			call: func(ctx context.Context) error {
+				_, err := client.GetStatus(ctx, &generated.GetStatusRequest{})
+				return err
+			},
+			code: codes.OK,
+		},
+		{name: "missing key", call: getTodos, code: codes.Unauthenticated},
+		{name: "malformed key", call: getTodos, metadata: []string{API_KEY_METADATA, "secret"}, code: codes.Unauthenticated},
+		{name: "wrong secret", call: getTodos, metadata: []string{API_KEY_METADATA, read + "0"}, code: codes.Unauthenticated},
+		{name: "revoked key", call: getTodos, metadata: []string{API_KEY_METADATA, revoked}, code: codes.Unauthenticated},
+		{name: "read key reading", call: getTodos, metadata: []string{API_KEY_METADATA, read}, code: codes.OK},
+		{name: "read key writing", call: createTodo, metadata: []string{API_KEY_METADATA, read}, code: codes.PermissionDenied},
+		{name: "write key writing", call: createTodo, metadata: []string{API_KEY_METADATA, write}, code: codes.OK},
+		{name: "key as a bearer token", call: createTodo, metadata: []string{"authorization", BEARER_PREFIX + write}, code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if len(tt.metadata) > 0 {
+				ctx = metadata.AppendToOutgoingContext(ctx, tt.metadata...)
+			}
+			if code := status.Code(tt.call(ctx)); code != tt.code {
+				t.Errorf("expected code %s, got %s", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestTodoService(t *testing.T) {
+	client := newTestClient(t, &GRPCServerConfig{})
+	ctx := context.Background()
+
+	created, err := client.CreateTodo(ctx, &generated.CreateTodoRequest{Description: "write tests"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.GetValue().GetId()
+
+	updated, err := client.UpdateTodo(ctx, &generated.UpdateTodoRequest{TodoId: id, Done: true, Description: "write more tests"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo := updated.GetValue(); !todo.GetDone() || todo.GetDescription() != "write more tests" {
+		t.Errorf("expected the todo to be updated, got %+v", todo)
+	}
+
+	got, err := client.GetTodo(ctx, &generated.GetTodoRequest{TodoId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetValue().GetDescription() != "write more tests" {
+		t.Errorf("expected the updated todo, got %+v", got.GetValue())
+	}
+
+	todos, err := client.GetTodos(ctx, &generated.GetTodosRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos.GetValue()) != 1 {
+		t.Errorf("expected 1 todo, got %d", len(todos.GetValue()))
+	}
+
+	if _, err := client.DeleteTodo(ctx, &generated.DeleteTodoRequest{TodoId: id}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{
+			name: "get a deleted todo",
+			call: func() error {
+				_, err := client.GetTodo(ctx, &generated.GetTodoRequest{TodoId: id})
+				return err
+			},
+			code: codes.NotFound,
+		},
+		{
+			name: "update a deleted todo",
+			call: func() error {
+				_, err := client.UpdateTodo(ctx, &generated.UpdateTodoRequest{TodoId: id, Description: "gone"})
+				return err
+			},
+			code: codes.NotFound,
+		},
+		{
+			name: "malformed id",
+			call: func() error {
+				_, err := client.GetTodo(ctx, &generated.GetTodoRequest{TodoId: "1"})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "empty description",
+			call: func() error {
+				_, err := client.CreateTodo(ctx, &generated.CreateTodoRequest{Description: ""})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "description too long",
+			call: func() error {
+				_, err := client.CreateTodo(ctx, &generated.CreateTodoRequest{Description: strings.Repeat("a", domain.MAX_DESCRIPTION_LENGTH+1)})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(tt.call()); code != tt.code {
+				t.Errorf("expected code %s, got %s", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestNamespaceInterceptor(t *testing.T) {
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespaces, err := tenant.New(func(string) (domain.TodoRepository, error) {
+		return memory.New(log), nil
+	}, &tenant.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := namespaces.Create("team", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := auth.NewKeyStore()
+	client := newTestClient(t, &GRPCServerConfig{Repo: namespaces, Namespaces: namespaces, Auth: keys})
+	key := issueTestKey(t, keys, domain.ScopeWrite)
+
+	tests := []struct {
+		name      string
+		key       string
+		namespace string
+		code      codes.Code
+	}{
+		{name: "namespace", key: key, namespace: "team", code: codes.OK},
+		{name: "unknown namespace", key: key, namespace: "missing", code: codes.NotFound},
+		// unauthenticated callers cannot tell which namespaces exist
+		{name: "unknown namespace without a key", namespace: "missing", code: codes.Unauthenticated},
+		{name: "namespace without a key", namespace: "team", code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := []string{NAMESPACE_METADATA, tt.namespace}
+			if tt.key != "" {
+				md = append(md, API_KEY_METADATA, tt.key)
+			}
+			ctx := metadata.AppendToOutgoingContext(context.Background(), md...)
+
+			_, err := client.CreateTodo(ctx, &generated.CreateTodoRequest{Description: tt.name})
+			if code := status.Code(err); code != tt.code {
+				t.Errorf("expected code %s, got %s", tt.code, code)
+			}
+		})
+	}
+
+	// the todo was created in the namespace, not the default store
+	ctx := metadata.AppendToOutgoingContext(context.Background(), API_KEY_METADATA, key)
+	todos, err := client.GetTodos(ctx, &generated.GetTodosRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos.GetValue()) != 0 {
+		t.Errorf("expected the default namespace to be empty, got %d todos", len(todos.GetValue()))
+	}
+}
+
+// newTestClient serves the todo service over an in-memory connection, filling
+// in the repository and logger when config leaves them unset
+func newTestClient(t *testing.T, config *GRPCServerConfig) generated.TodoServiceClient {
+	t.Helper()
+
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Log == nil {
+		config.Log = log
+	}
+	if config.Repo == nil {
+		config.Repo = memory.New(log)
+	}
+
+	server, err := CreateGRPCServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	go server.Serve(lis)
+	server.SetReady(true)
+	t.Cleanup(server.Server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return generated.NewTodoServiceClient(conn)
+}
+
+func issueTestKey(t *testing.T, keys *auth.KeyStore, scope domain.Scope) string {
+	t.Helper()
+
+	key, _, err := keys.Issue(string(scope), []domain.Scope{scope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
@@ -0,0 +1,159 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/auth"
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/grpc/generated"
+	"github.com/brendenehlers/todo-microservice/tenant"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	REQUEST_ID_METADATA   = "x-request-id"
+	MAX_REQUEST_ID_LENGTH = 128
+	API_KEY_METADATA      = "x-api-key"
+	BEARER_PREFIX         = "Bearer "
+	NAMESPACE_METADATA    = "x-namespace"
+)
+
+// requestIDInterceptor tags every call with the id in its x-request-id
+// metadata, or a new one, and returns it in the response header
+func requestIDInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(REQUEST_ID_METADATA); len(ids) > 0 {
+			id = ids[0]
+		}
+	}
+	if !validRequestID(id) {
+		id = uuid.NewString()
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(REQUEST_ID_METADATA, id))
+	return handler(domain.ContextWithRequestID(ctx, id), req)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// namespaceInterceptor puts the namespace selected by the x-namespace
+// metadata on the call context
+func namespaceInterceptor(namespaces *tenant.Registry, log domain.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		names := md.Get(NAMESPACE_METADATA)
+		if len(names) == 0 || names[0] == "" {
+			return handler(ctx, req)
+		}
+
+		callLog := domain.LoggerFromContext(ctx, log).With("namespace", names[0])
+		namespace, err := namespaces.Lookup(names[0])
+		if err != nil {
+			callLog.Warn(err.Error())
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+
+		ctx = domain.ContextWithNamespace(ctx, namespace)
+		return handler(domain.ContextWithLogger(ctx, callLog), req)
+	}
+}
+
+// loggingInterceptor logs every completed call and puts a logger carrying the
+// request id and method on the context for the service to use
+func loggingInterceptor(log domain.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		callLog := log.With(
+			"requestId", domain.RequestIDFromContext(ctx),
+			"method", info.FullMethod,
+		)
+
+		start := time.Now()
+		resp, err := handler(domain.ContextWithLogger(ctx, callLog), req)
+
+		code := status.Code(err)
+		args := []any{
+			"code", code.String(),
+			"duration", time.Since(start),
+		}
+		switch code {
+		case codes.OK:
+			callLog.Info("Call completed", args...)
+		case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+			callLog.Error("Call completed", args...)
+		default:
+			callLog.Warn("Call completed", args...)
+		}
+
+		return resp, err
+	}
+}
+
+// methodScopes lists the scope each method requires, matching the REST
+// operations. Methods not listed, like GetStatus and reflection, are public
+var methodScopes = map[string]domain.Scope{
+	generated.TodoService_CreateTodo_FullMethodName: domain.ScopeWrite,
+	generated.TodoService_GetTodos_FullMethodName:   domain.ScopeRead,
+	generated.TodoService_GetTodo_FullMethodName:    domain.ScopeRead,
+	generated.TodoService_UpdateTodo_FullMethodName: domain.ScopeWrite,
+	generated.TodoService_DeleteTodo_FullMethodName: domain.ScopeWrite,
+}
+
+// authInterceptor authenticates callers of methods that require a scope with
+// the x-api-key or authorization metadata, and puts the principal on the context
+func authInterceptor(authn auth.Authenticator, log domain.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		scope, ok := methodScopes[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		callLog := domain.LoggerFromContext(ctx, log)
+		principal, err := authn.Authenticate(ctx, metadataCredentials(ctx))
+		if err != nil {
+			callLog.Warn("authentication failed", "error", err.Error())
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if !principal.HasScope(scope) {
+			callLog.Warn(ErrForbidden.Error(), "principal", principal.Id)
+			return nil, status.Error(codes.PermissionDenied, ErrForbidden.Error())
+		}
+
+		ctx = domain.ContextWithPrincipal(ctx, principal)
+		ctx = domain.ContextWithLogger(ctx, callLog.With("principal", principal.Id))
+		return handler(ctx, req)
+	}
+}
+
+func metadataCredentials(ctx context.Context) auth.Credentials {
+	var creds auth.Credentials
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return creds
+	}
+
+	if keys := md.Get(API_KEY_METADATA); len(keys) > 0 {
+		creds.APIKey = keys[0]
+	}
+	if values := md.Get("authorization"); len(values) > 0 && strings.HasPrefix(values[0], BEARER_PREFIX) {
+		creds.BearerToken = strings.TrimPrefix(values[0], BEARER_PREFIX)
+	}
+
+	return creds
+}
@@ -0,0 +1,189 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/grpc/generated"
+	"github.com/brendenehlers/todo-microservice/health"
+	"github.com/brendenehlers/todo-microservice/sharing"
+	"github.com/brendenehlers/todo-microservice/tenant"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newTodoService(repo domain.TodoRepository, log domain.Logger, ready *atomic.Bool, health *health.Registry) *todoService {
+	return &todoService{
+		repo:   repo,
+		log:    log,
+		ready:  ready,
+		health: health,
+	}
+}
+
+type todoService struct {
+	generated.UnimplementedTodoServiceServer
+	repo   domain.TodoRepository
+	log    domain.Logger
+	ready  *atomic.Bool
+	health *health.Registry
+}
+
+// GetStatus agrees with the REST readiness probe, failing with Unavailable
+// while the server is starting or shutting down, or a dependency is down
+func (s *todoService) GetStatus(ctx context.Context, req *generated.GetStatusRequest) (*generated.Status, error) {
+	if !s.ready.Load() {
+		s.logger(ctx).Warn("Status check failed", "reason", "server is not accepting traffic")
+		return nil, status.Error(codes.Unavailable, "server is not accepting traffic")
+	}
+
+	report := s.health.Run(ctx)
+	if !report.Up() {
+		for _, check := range report.Checks {
+			if check.Status != health.STATUS_UP {
+				s.logger(ctx).Warn("Status check failed", "check", check.Name, "error", check.Error)
+				return nil, status.Errorf(codes.Unavailable, "%s is %s: %s", check.Name, check.Status, check.Error)
+			}
+		}
+	}
+
+	return &generated.Status{
+		Status: "ok",
+	}, nil
+}
+
+func (s *todoService) CreateTodo(ctx context.Context, req *generated.CreateTodoRequest) (*generated.TodoResponse, error) {
+	todo, err := s.repo.CreateTodo(ctx, &domain.NewTodo{
+		Description: req.GetDescription(),
+	})
+	if err != nil {
+		return nil, s.requestError(ctx, err)
+	}
+
+	s.logger(ctx).Info("Successfully created todo")
+	return &generated.TodoResponse{
+		Value: convertDomainTodoToGeneratedTodo(todo),
+	}, nil
+}
+
+func (s *todoService) GetTodos(ctx context.Context, req *generated.GetTodosRequest) (*generated.TodosResponse, error) {
+	domainTodos, err := s.repo.GetTodos(ctx)
+	if err != nil {
+		return nil, s.requestError(ctx, err)
+	}
+
+	todos := make([]*generated.Todo, 0)
+	for _, dTodo := range *domainTodos {
+		todos = append(todos, convertDomainTodoToGeneratedTodo(&dTodo))
+	}
+
+	s.logger(ctx).Info("Successfully retrieved todos")
+	return &generated.TodosResponse{
+		Value: todos,
+	}, nil
+}
+
+func (s *todoService) GetTodo(ctx context.Context, req *generated.GetTodoRequest) (*generated.TodoResponse, error) {
+	id, err := parseTodoID(req.GetTodoId())
+	if err != nil {
+		return nil, err
+	}
+
+	todo, err := s.repo.GetTodo(ctx, id)
+	if err != nil {
+		return nil, s.requestError(ctx, err)
+	}
+
+	s.logger(ctx).Info("Successfully found todo", "todoId", id)
+	return &generated.TodoResponse{
+		Value: convertDomainTodoToGeneratedTodo(todo),
+	}, nil
+}
+
+func (s *todoService) UpdateTodo(ctx context.Context, req *generated.UpdateTodoRequest) (*generated.TodoResponse, error) {
+	id, err := parseTodoID(req.GetTodoId())
+	if err != nil {
+		return nil, err
+	}
+
+	todo, err := s.repo.UpdateTodo(ctx, id, &domain.UpdateTodo{
+		Done:        req.GetDone(),
+		Description: req.GetDescription(),
+	})
+	if err != nil {
+		return nil, s.requestError(ctx, err)
+	}
+
+	s.logger(ctx).Info("Successfully updated todo", "todoId", id)
+	return &generated.TodoResponse{
+		Value: convertDomainTodoToGeneratedTodo(todo),
+	}, nil
+}
+
+func (s *todoService) DeleteTodo(ctx context.Context, req *generated.DeleteTodoRequest) (*generated.MessageResponse, error) {
+	id, err := parseTodoID(req.GetTodoId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.DeleteTodo(ctx, id); err != nil {
+		return nil, s.requestError(ctx, err)
+	}
+
+	msg := "Successfully deleted todo"
+	s.logger(ctx).Info(msg, "todoId", id)
+	return &generated.MessageResponse{
+		Message: msg,
+	}, nil
+}
+
+func (s *todoService) logger(ctx context.Context) domain.Logger {
+	return domain.LoggerFromContext(ctx, s.log)
+}
+
+func (s *todoService) requestError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrTodoNotFound), errors.Is(err, tenant.ErrNamespaceNotFound):
+		s.logger(ctx).Warn(err.Error())
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrEmptyDescription), errors.Is(err, domain.ErrDescriptionTooLong):
+		s.logger(ctx).Warn(err.Error())
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, sharing.ErrInsufficientRole):
+		s.logger(ctx).Warn(err.Error())
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, tenant.ErrQuotaExceeded):
+		s.logger(ctx).Warn(err.Error())
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	s.logger(ctx).Error(err.Error())
+	return status.Error(codes.Internal, err.Error())
+}
+
+// parseTodoID validates the id the same way the REST API does for its
+// uuid path parameter
+func parseTodoID(id string) (string, error) {
+	uuidObj, err := uuid.Parse(id)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "invalid format for parameter todo_id: %s", err)
+	}
+
+	return uuidObj.String(), nil
+}
+
+func convertDomainTodoToGeneratedTodo(todo *domain.Todo) *generated.Todo {
+	return &generated.Todo{
+		Id:          todo.Id,
+		OwnerId:     todo.OwnerId,
+		Done:        todo.Done,
+		Description: todo.Description,
+		CreatedAt:   timestamppb.New(todo.CreatedAt),
+		UpdatedAt:   timestamppb.New(todo.UpdatedAt),
+		DoneAt:      timestamppb.New(todo.DoneAt),
+	}
+}
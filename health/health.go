@@ -0,0 +1,168 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	STATUS_UP   = "up"
+	STATUS_DOWN = "down"
+
+	DEFAULT_CACHE_TTL     = time.Second * 5
+	DEFAULT_CHECK_TIMEOUT = time.Second
+)
+
+var (
+	ErrCheckTimedOut = fmt.Errorf("health check timed out")
+)
+
+// Check reports whether a dependency is usable, returning nil when it is
+type Check func(ctx context.Context) error
+
+type Result struct {
+	Name      string
+	Status    string
+	Error     string
+	Latency   time.Duration
+	CheckedAt time.Time
+}
+
+type Report struct {
+	Status string
+	Checks []Result
+}
+
+func (r *Report) Up() bool {
+	return r.Status == STATUS_UP
+}
+
+func New(cacheTTL time.Duration, checkTimeout time.Duration) *Registry {
+	if cacheTTL < 0 {
+		cacheTTL = 0
+	}
+	if checkTimeout <= 0 {
+		checkTimeout = DEFAULT_CHECK_TIMEOUT
+	}
+
+	return &Registry{
+		checks:       make(map[string]*registeredCheck),
+		cacheTTL:     cacheTTL,
+		checkTimeout: checkTimeout,
+	}
+}
+
+// Registry runs the health checks dependencies register with it, caching each
+// result so frequent probes do not overload the dependencies
+type Registry struct {
+	mu           sync.Mutex
+	checks       map[string]*registeredCheck
+	cacheTTL     time.Duration
+	checkTimeout time.Duration
+}
+
+type registeredCheck struct {
+	mu     sync.Mutex
+	check  Check
+	result *Result
+}
+
+// Register adds a check under name, replacing any check already registered with that name
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks[name] = &registeredCheck{check: check}
+}
+
+// Run runs every check concurrently and reports the service up only if all of them pass
+func (r *Registry) Run(ctx context.Context) *Report {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.checks))
+	checks := make([]*registeredCheck, 0, len(r.checks))
+	for name, c := range r.checks {
+		names = append(names, name)
+		checks = append(checks, c)
+	}
+	r.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, names[i], c)
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	report := &Report{
+		Status: STATUS_UP,
+		Checks: results,
+	}
+	for _, result := range results {
+		if result.Status != STATUS_UP {
+			report.Status = STATUS_DOWN
+		}
+	}
+
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, name string, c *registeredCheck) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.result != nil && time.Since(c.result.CheckedAt) < r.cacheTTL {
+		return *c.result
+	}
+
+	// the check is bounded by the check timeout alone, so a caller that goes
+	// away does not cut it short and fail it for everyone reading the cache
+	checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	errch := make(chan error, 1)
+	go func() {
+		errch <- c.check(checkCtx)
+	}()
+
+	var err error
+	select {
+	case err = <-errch:
+	case <-checkCtx.Done():
+		err = ErrCheckTimedOut
+	case <-ctx.Done():
+		// the caller stopped waiting, which says nothing about the dependency,
+		// so the result is not cached
+		return Result{
+			Name:    name,
+			Status:  STATUS_DOWN,
+			Error:   ctx.Err().Error(),
+			Latency: time.Since(start),
+		}
+	}
+
+	result := &Result{
+		Name:      name,
+		Status:    STATUS_UP,
+		Latency:   time.Since(start),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = STATUS_DOWN
+		result.Error = err.Error()
+	}
+
+	c.result = result
+	return *result
+}
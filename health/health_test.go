@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	r := New(time.Hour, 50*time.Millisecond)
+
+	var calls atomic.Int32
+	r.Register("up", func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+	r.Register("down", func(ctx context.Context) error {
+		return errors.New("unreachable")
+	})
+	r.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := r.Run(context.Background())
+	if report.Up() {
+		t.Error("expected the report to be down while a check fails")
+	}
+
+	expected := []struct {
+		name   string
+		status string
+		err    string
+	}{
+		{"down", STATUS_DOWN, "unreachable"},
+		{"slow", STATUS_DOWN, ErrCheckTimedOut.Error()},
+		{"up", STATUS_UP, ""},
+	}
+	if len(report.Checks) != len(expected) {
+		t.Fatalf("expected %d results, got %+v", len(expected), report.Checks)
+	}
+	for i, e := range expected {
+		if c := report.Checks[i]; c.Name != e.name || c.Status != e.status || c.Error != e.err {
+			t.Errorf("expected %s to be %s with error %q, got %+v", e.name, e.status, e.err, c)
+		}
+	}
+
+	// the results are cached for the ttl
+	r.Run(context.Background())
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected the cached result to be reused, the check ran %d times", n)
+	}
+}
+
+func TestRunAfterCallerCancels(t *testing.T) {
+	r := New(time.Hour, time.Second)
+
+	release := make(chan struct{})
+	var calls atomic.Int32
+	r.Register("store", func(ctx context.Context) error {
+		calls.Add(1)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if report := r.Run(ctx); report.Up() {
+		t.Fatal("expected the check to be reported down to the caller that went away")
+	}
+
+	// the cut short result was not cached, so the check runs again
+	close(release)
+	if report := r.Run(context.Background()); !report.Up() {
+		t.Errorf("expected the check to be up once it can finish, got %+v", report.Checks)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected the check to run twice, it ran %d times", n)
+	}
+}
@@ -1,25 +1,35 @@
 package http
 
 import (
+	"context"
+	"errors"
+
 	"github.com/brendenehlers/todo-microservice/domain"
 	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/brendenehlers/todo-microservice/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func newAdapter(repo domain.TodoRepository) *adapter {
 	return &adapter{
-		repo: repo,
+		repo:   repo,
+		tracer: tracing.Tracer("http"),
 	}
 }
 
 type adapter struct {
-	repo domain.TodoRepository
+	repo   domain.TodoRepository
+	tracer trace.Tracer
 }
 
-func (a *adapter) CreateTodo(newTodo *generated.CreateTodoJSONRequestBody) (*generated.Todo, error) {
+func (a *adapter) CreateTodo(ctx context.Context, newTodo *generated.CreateTodoJSONRequestBody) (*generated.Todo, error) {
+	ctx, span := a.tracer.Start(ctx, "adapter.CreateTodo")
+	defer span.End()
+
 	domainNewTodo := convertGeneratedNewTodoToDomainNewTodo(newTodo)
 
-	domainTodo, err := a.repo.CreateTodo(domainNewTodo)
+	domainTodo, err := a.repo.CreateTodo(ctx, domainNewTodo)
 	if err != nil {
 		return nil, err
 	}
@@ -27,10 +37,60 @@ func (a *adapter) CreateTodo(newTodo *generated.CreateTodoJSONRequestBody) (*gen
 	return covertDomainTodoToGeneratedTodo(domainTodo)
 }
 
-func (a *adapter) GetTodo(id *generated.TodoID) (*generated.Todo, error) {
+func (a *adapter) CreateTodos(ctx context.Context, newTodos *[]generated.CreateTodoJSONRequestBody) (*[]generated.Todo, error) {
+	ctx, span := a.tracer.Start(ctx, "adapter.CreateTodos")
+	defer span.End()
+
+	domainNewTodos := make([]domain.NewTodo, 0, len(*newTodos))
+	for _, newTodo := range *newTodos {
+		domainNewTodos = append(domainNewTodos, *convertGeneratedNewTodoToDomainNewTodo(&newTodo))
+	}
+
+	domainTodos, err := a.repo.CreateTodos(ctx, &domainNewTodos)
+	if err != nil {
+		return nil, err
+	}
+
+	todos := make([]generated.Todo, 0)
+	for _, dTodo := range *domainTodos {
+		todo, err := covertDomainTodoToGeneratedTodo(&dTodo)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *todo)
+	}
+
+	return &todos, nil
+}
+
+func (a *adapter) SaveTodos(ctx context.Context, writes *[]domain.TodoWrite) (*[]generated.Todo, error) {
+	ctx, span := a.tracer.Start(ctx, "adapter.SaveTodos")
+	defer span.End()
+
+	domainTodos, err := a.repo.SaveTodos(ctx, writes)
+	if err != nil {
+		return nil, err
+	}
+
+	todos := make([]generated.Todo, 0, len(*domainTodos))
+	for _, dTodo := range *domainTodos {
+		todo, err := covertDomainTodoToGeneratedTodo(&dTodo)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *todo)
+	}
+
+	return &todos, nil
+}
+
+func (a *adapter) GetTodo(ctx context.Context, id *generated.TodoID) (*generated.Todo, error) {
+	ctx, span := a.tracer.Start(ctx, "adapter.GetTodo")
+	defer span.End()
+
 	idStr := id.String()
 
-	domainTodo, err := a.repo.GetTodo(idStr)
+	domainTodo, err := a.repo.GetTodo(ctx, idStr)
 	if err != nil {
 		return nil, err
 	}
@@ -38,8 +98,11 @@ func (a *adapter) GetTodo(id *generated.TodoID) (*generated.Todo, error) {
 	return covertDomainTodoToGeneratedTodo(domainTodo)
 }
 
-func (a *adapter) GetTodos() (*[]generated.Todo, error) {
-	domainTodos, err := a.repo.GetTodos()
+func (a *adapter) GetTodos(ctx context.Context) (*[]generated.Todo, error) {
+	ctx, span := a.tracer.Start(ctx, "adapter.GetTodos")
+	defer span.End()
+
+	domainTodos, err := a.repo.GetTodos(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -56,11 +119,51 @@ func (a *adapter) GetTodos() (*[]generated.Todo, error) {
 	return &todos, nil
 }
 
-func (a *adapter) UpdateTodo(id *generated.TodoID, update *generated.UpdateTodoJSONRequestBody) (*generated.Todo, error) {
+// ExportTodos returns the todos in creation order, reading them a page at a
+// time as they are iterated. The first page is read right away, so failing to
+// read it can still be reported before the response is started
+func (a *adapter) ExportTodos(ctx context.Context) (todoIterator, error) {
+	ctx, span := a.tracer.Start(ctx, "adapter.ExportTodos")
+	defer span.End()
+
+	page, err := a.repo.GetTodosPage(ctx, nil, EXPORT_PAGE_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(fn func(i int, todo *generated.Todo) error) error {
+		i := 0
+		for {
+			for _, dTodo := range *page {
+				todo, err := covertDomainTodoToGeneratedTodo(&dTodo)
+				if err != nil {
+					return err
+				}
+				if err := fn(i, todo); err != nil {
+					return err
+				}
+				i++
+			}
+			if len(*page) < EXPORT_PAGE_SIZE {
+				return nil
+			}
+
+			after := domain.CursorOf(&(*page)[len(*page)-1])
+			if page, err = a.repo.GetTodosPage(ctx, &after, EXPORT_PAGE_SIZE); err != nil {
+				return err
+			}
+		}
+	}, nil
+}
+
+func (a *adapter) UpdateTodo(ctx context.Context, id *generated.TodoID, update *generated.UpdateTodoJSONRequestBody) (*generated.Todo, error) {
+	ctx, span := a.tracer.Start(ctx, "adapter.UpdateTodo")
+	defer span.End()
+
 	idStr := id.String()
 	domainUpdateTodo := convertGeneratedUpdateTodoToDomainUpdateTodo(update)
 
-	todo, err := a.repo.UpdateTodo(idStr, domainUpdateTodo)
+	todo, err := a.repo.UpdateTodo(ctx, idStr, domainUpdateTodo)
 	if err != nil {
 		return nil, err
 	}
@@ -68,15 +171,168 @@ func (a *adapter) UpdateTodo(id *generated.TodoID, update *generated.UpdateTodoJ
 	return covertDomainTodoToGeneratedTodo(todo)
 }
 
-func (a *adapter) DeleteTodo(id *generated.TodoID) error {
+func (a *adapter) DeleteTodo(ctx context.Context, id *generated.TodoID) error {
+	ctx, span := a.tracer.Start(ctx, "adapter.DeleteTodo")
+	defer span.End()
+
 	idStr := id.String()
 
-	return a.repo.DeleteTodo(idStr)
+	return a.repo.DeleteTodo(ctx, idStr)
+}
+
+func (a *adapter) GetChanges(ctx context.Context, since domain.SyncPosition) (*[]generated.Change, domain.SyncPosition, error) {
+	ctx, span := a.tracer.Start(ctx, "adapter.GetChanges")
+	defer span.End()
+
+	domainChanges, latest, err := a.repo.GetChanges(ctx, since)
+	if err != nil {
+		return nil, domain.SyncPosition{}, err
+	}
+
+	changes := make([]generated.Change, 0)
+	for _, dChange := range *domainChanges {
+		change, err := convertDomainChangeToGeneratedChange(&dChange)
+		if err != nil {
+			return nil, domain.SyncPosition{}, err
+		}
+		changes = append(changes, *change)
+	}
+
+	return &changes, latest, nil
+}
+
+func (a *adapter) ApplyChanges(ctx context.Context, changes *generated.ApplyChangesJSONRequestBody) (*[]generated.ChangeResult, error) {
+	ctx, span := a.tracer.Start(ctx, "adapter.ApplyChanges")
+	defer span.End()
+
+	results := make([]generated.ChangeResult, 0, len(changes.Changes))
+	for _, change := range changes.Changes {
+		result := a.applyChange(ctx, &change)
+		result.ClientId = change.ClientId
+		results = append(results, *result)
+	}
+
+	return &results, nil
+}
+
+// applyChange applies a single client change. Updates and deletes conflict
+// when the todo has changed on the server since the client's base sequence
+func (a *adapter) applyChange(ctx context.Context, change *generated.ClientChange) *generated.ChangeResult {
+	if change.Op == generated.Create {
+		todo, err := a.repo.CreateTodo(ctx, &domain.NewTodo{
+			Description: valueOrZero(change.Description),
+			Done:        valueOrZero(change.Done),
+		})
+		if err != nil {
+			return changeError(err)
+		}
+
+		return changeResult(generated.ChangeResultStatusApplied, &domain.TodoChange{Seq: todo.Seq, Todo: *todo})
+	}
+
+	if change.Id == nil {
+		return changeError(ErrInvalidChange)
+	}
+	id := change.Id.String()
+	baseSeq := uint64(valueOrZero(change.BaseSeq))
+
+	current, err := a.repo.GetTodo(ctx, id)
+	if err != nil {
+		return a.changeFailed(ctx, change, id, err)
+	}
+	if current.Seq > baseSeq {
+		return changeResult(generated.ChangeResultStatusConflict, &domain.TodoChange{Seq: current.Seq, Todo: *current})
+	}
+
+	// the writes only succeed if the todo is still the one read above, so a
+	// concurrent change to it is reported as a conflict rather than overwritten
+	switch change.Op {
+	case generated.Update:
+		update := &domain.UpdateTodo{
+			Done:        current.Done,
+			Description: current.Description,
+		}
+		if change.Done != nil {
+			update.Done = *change.Done
+		}
+		if change.Description != nil {
+			update.Description = *change.Description
+		}
+
+		todo, err := a.repo.UpdateTodoIfUnchanged(ctx, id, current.Seq, update)
+		if err != nil {
+			return a.changeFailed(ctx, change, id, err)
+		}
+
+		return changeResult(generated.ChangeResultStatusApplied, &domain.TodoChange{Seq: todo.Seq, Todo: *todo})
+	case generated.Delete:
+		if err := a.repo.DeleteTodoIfUnchanged(ctx, id, current.Seq); err != nil {
+			return a.changeFailed(ctx, change, id, err)
+		}
+
+		return changeResult(generated.ChangeResultStatusApplied, &domain.TodoChange{Deleted: true, Todo: domain.Todo{Id: id}})
+	default:
+		return changeError(ErrInvalidChange)
+	}
+}
+
+// changeFailed reports a change to a todo that is gone or was changed by
+// someone else as a conflict with its current state. Any other error is
+// reported as is
+func (a *adapter) changeFailed(ctx context.Context, change *generated.ClientChange, id string, err error) *generated.ChangeResult {
+	if errors.Is(err, domain.ErrTodoChanged) {
+		var current *domain.Todo
+		if current, err = a.repo.GetTodo(ctx, id); err == nil {
+			return changeResult(generated.ChangeResultStatusConflict, &domain.TodoChange{Seq: current.Seq, Todo: *current})
+		}
+	}
+	if !errors.Is(err, domain.ErrTodoNotFound) {
+		return changeError(err)
+	}
+
+	// deleting a todo that is already gone is not a conflict
+	if change.Op == generated.Delete {
+		return changeResult(generated.ChangeResultStatusApplied, &domain.TodoChange{Deleted: true, Todo: domain.Todo{Id: id}})
+	}
+	return changeResult(generated.ChangeResultStatusConflict, &domain.TodoChange{Deleted: true, Todo: domain.Todo{Id: id}})
+}
+
+func changeResult(status generated.ChangeResultStatus, change *domain.TodoChange) *generated.ChangeResult {
+	current, err := convertDomainChangeToGeneratedChange(change)
+	if err != nil {
+		return changeError(err)
+	}
+
+	return &generated.ChangeResult{
+		Id:      current.Todo.Id,
+		Status:  &status,
+		Current: current,
+	}
+}
+
+func changeError(err error) *generated.ChangeResult {
+	status := generated.ChangeResultStatusError
+	errStr := err.Error()
+
+	return &generated.ChangeResult{
+		Status: &status,
+		Error:  &errStr,
+	}
+}
+
+func valueOrZero[T any](val *T) T {
+	var zero T
+	if val == nil {
+		return zero
+	}
+
+	return *val
 }
 
 func convertGeneratedNewTodoToDomainNewTodo(newTodo *generated.CreateTodoJSONRequestBody) *domain.NewTodo {
 	return &domain.NewTodo{
-		Description: *newTodo.Description,
+		Description: newTodo.Description,
+		Done:        valueOrZero(newTodo.Done),
 	}
 }
 
@@ -88,6 +344,7 @@ func covertDomainTodoToGeneratedTodo(todo *domain.Todo) (*generated.Todo, error)
 
 	return &generated.Todo{
 		Id:          &uuidObj,
+		OwnerId:     &todo.OwnerId,
 		Done:        &todo.Done,
 		Description: &todo.Description,
 		DoneAt:      &todo.DoneAt,
@@ -96,9 +353,23 @@ func covertDomainTodoToGeneratedTodo(todo *domain.Todo) (*generated.Todo, error)
 	}, nil
 }
 
+func convertDomainChangeToGeneratedChange(change *domain.TodoChange) (*generated.Change, error) {
+	todo, err := covertDomainTodoToGeneratedTodo(&change.Todo)
+	if err != nil {
+		return nil, err
+	}
+
+	seq := int64(change.Seq)
+	return &generated.Change{
+		Seq:     &seq,
+		Deleted: &change.Deleted,
+		Todo:    todo,
+	}, nil
+}
+
 func convertGeneratedUpdateTodoToDomainUpdateTodo(todo *generated.UpdateTodoJSONRequestBody) *domain.UpdateTodo {
 	return &domain.UpdateTodo{
-		Done:        *todo.Done,
-		Description: *todo.Description,
+		Done:        todo.Done,
+		Description: todo.Description,
 	}
 }
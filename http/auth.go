@@ -0,0 +1,114 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/brendenehlers/todo-microservice/auth"
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+)
+
+const (
+	API_KEY_HEADER = "X-API-Key"
+	BEARER_PREFIX  = "Bearer "
+)
+
+// Authenticate checks the caller of every operation whose security
+// requirement lists scopes, and puts the principal on the request context.
+// Operations with an empty security requirement are public
+func Authenticate(authn auth.Authenticator, log domain.Logger) generated.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := r.Context().Value(generated.ApiKeyScopes).([]string)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, ok := authenticateRequest(w, r, authn, log)
+			if !ok {
+				return
+			}
+			for _, scope := range scopes {
+				if !principal.HasScope(domain.Scope(scope)) {
+					forbidden(w, r, log, principal)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(principalContext(r, principal, log)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// requireScope protects handlers outside the spec, like the GraphQL endpoint
+func requireScope(authn auth.Authenticator, scope domain.Scope, log domain.Logger, next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := authenticateRequest(w, r, authn, log)
+		if !ok {
+			return
+		}
+		if !principal.HasScope(scope) {
+			forbidden(w, r, log, principal)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(principalContext(r, principal, log)))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// authenticateRequest writes a 401 response and returns false if the request
+// cannot be authenticated
+func authenticateRequest(w http.ResponseWriter, r *http.Request, authn auth.Authenticator, log domain.Logger) (*domain.Principal, bool) {
+	principal, err := authn.Authenticate(r.Context(), requestCredentials(r))
+	if err == nil {
+		return principal, true
+	}
+
+	reqLog := domain.LoggerFromContext(r.Context(), log)
+	if errors.Is(err, auth.ErrMissingCredentials) {
+		reqLog.Warn(err.Error())
+	} else {
+		reqLog.Warn("authentication failed", "error", err.Error())
+	}
+
+	w.Header().Set("WWW-Authenticate", authn.Scheme())
+	sendError(w, r, http.StatusUnauthorized, err.Error())
+	return nil, false
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request, log domain.Logger, principal *domain.Principal) {
+	domain.LoggerFromContext(r.Context(), log).Warn(ErrForbidden.Error(), "principal", principal.Id)
+	sendError(w, r, http.StatusForbidden, ErrForbidden.Error())
+}
+
+func principalContext(r *http.Request, principal *domain.Principal, log domain.Logger) context.Context {
+	ctx := domain.ContextWithPrincipal(r.Context(), principal)
+	reqLog := domain.LoggerFromContext(ctx, log).With("principal", principal.Id)
+	return domain.ContextWithLogger(ctx, reqLog)
+}
+
+func requestCredentials(r *http.Request) auth.Credentials {
+	creds := auth.Credentials{
+		APIKey: r.Header.Get(API_KEY_HEADER),
+	}
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, BEARER_PREFIX) {
+		creds.BearerToken = strings.TrimPrefix(header, BEARER_PREFIX)
+	}
+
+	return creds
+}
+
+func sendError(w http.ResponseWriter, r *http.Request, status int, errStr string) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse(r, errStr))
+}
@@ -0,0 +1,194 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brendenehlers/todo-microservice/auth"
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	keys := auth.NewKeyStore()
+	server := newAuthTestServer(t, keys)
+
+	read := issueTestKey(t, keys, domain.ScopeRead)
+	write := issueTestKey(t, keys, domain.ScopeWrite)
+	admin := issueTestKey(t, keys, domain.ScopeAdmin)
+	revoked, revokedKey, err := keys.Issue("revoked", []domain.Scope{domain.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Revoke(revokedKey.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	createTodo := `{"description": "write tests"}`
+	mutation := `{"query": "mutation { createTodo(input: {description: \"write tests\"}) { id } }"}`
+	query := `{"query": "{ todos { totalCount } }"}`
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		key    string
+		status int
+		// graphqlError is the error a GraphQL response carries despite its 200
+		graphqlError string
+	}{
+		{name: "public operation without a key", method: http.MethodGet, path: "/healthz", status: http.StatusOK},
+		{name: "missing key", method: http.MethodGet, path: "/todos", status: http.StatusUnauthorized},
+		{name: "malformed key", method: http.MethodGet, path: "/todos", key: "secret", status: http.StatusUnauthorized},
+		{name: "wrong secret", method: http.MethodGet, path: "/todos", key: read + "0", status: http.StatusUnauthorized},
+		{name: "revoked key", method: http.MethodGet, path: "/todos", key: revoked, status: http.StatusUnauthorized},
+		{name: "read key reading", method: http.MethodGet, path: "/todos", key: read, status: http.StatusOK},
+		{name: "read key writing", method: http.MethodPost, path: "/todo", body: createTodo, key: read, status: http.StatusForbidden},
+		{name: "write key writing", method: http.MethodPost, path: "/todo", body: createTodo, key: write, status: http.StatusOK},
+		{name: "write key managing keys", method: http.MethodGet, path: "/keys", key: write, status: http.StatusForbidden},
+		{name: "admin key managing keys", method: http.MethodGet, path: "/keys", key: admin, status: http.StatusOK},
+		{name: "graphql without a key", method: http.MethodPost, path: GRAPHQL_PATH, body: query, status: http.StatusUnauthorized},
+		{name: "graphql with a revoked key", method: http.MethodPost, path: GRAPHQL_PATH, body: query, key: revoked, status: http.StatusUnauthorized},
+		{name: "graphql query with a read key", method: http.MethodPost, path: GRAPHQL_PATH, body: query, key: read, status: http.StatusOK},
+		{name: "graphql mutation with a read key", method: http.MethodPost, path: GRAPHQL_PATH, body: mutation, key: read, status: http.StatusOK, graphqlError: ErrForbidden.Error()},
+		{name: "graphql mutation with a write key", method: http.MethodPost, path: GRAPHQL_PATH, body: mutation, key: write, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveWithKey(server, tt.method, tt.path, tt.body, tt.key)
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != auth.KEY_SCHEME {
+				t.Errorf("expected a %s challenge, got %q", auth.KEY_SCHEME, rec.Header().Get("WWW-Authenticate"))
+			}
+
+			if tt.path != GRAPHQL_PATH || tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Errors []struct{ Message string }
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.graphqlError == "" && len(resp.Errors) > 0:
+				t.Errorf("unexpected errors: %+v", resp.Errors)
+			case tt.graphqlError != "" && (len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.graphqlError)):
+				t.Errorf("expected the error %q, got %+v", tt.graphqlError, resp.Errors)
+			}
+		})
+	}
+}
+
+func TestAPIKeyManagement(t *testing.T) {
+	keys := auth.NewKeyStore()
+	server := newAuthTestServer(t, keys)
+	admin := issueTestKey(t, keys, domain.ScopeAdmin)
+
+	rec := serveWithKey(server, http.MethodPost, "/keys", `{"name": "reader", "scopes": ["read"]}`, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d issuing a key, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	issued := decodeAPIKey(t, rec.Body)
+	if rec := serveWithKey(server, http.MethodGet, "/todos", "", *issued.Key); rec.Code != http.StatusOK {
+		t.Errorf("expected the issued key to read todos, got status %d", rec.Code)
+	}
+
+	rec = serveWithKey(server, http.MethodPost, "/keys/"+*issued.Value.Id+"/rotate", "", admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d rotating the key, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	rotated := decodeAPIKey(t, rec.Body)
+	if rec := serveWithKey(server, http.MethodGet, "/todos", "", *issued.Key); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the replaced key to be rejected, got status %d", rec.Code)
+	}
+	if rec := serveWithKey(server, http.MethodGet, "/todos", "", *rotated.Key); rec.Code != http.StatusOK {
+		t.Errorf("expected the rotated key to read todos, got status %d", rec.Code)
+	}
+
+	rec = serveWithKey(server, http.MethodDelete, "/keys/"+*issued.Value.Id, "", admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d revoking the key, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if rec := serveWithKey(server, http.MethodGet, "/todos", "", *rotated.Key); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the revoked key to be rejected, got status %d", rec.Code)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "issue without scopes", method: http.MethodPost, path: "/keys", body: `{"name": "none", "scopes": []}`, status: http.StatusBadRequest},
+		{name: "rotate a revoked key", method: http.MethodPost, path: "/keys/" + *issued.Value.Id + "/rotate", status: http.StatusBadRequest},
+		{name: "revoke an unknown key", method: http.MethodDelete, path: "/keys/0123", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serveWithKey(server, tt.method, tt.path, tt.body, admin); rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func newAuthTestServer(t *testing.T, keys *auth.KeyStore) *HttpServer {
+	t.Helper()
+
+	return newConfiguredTestServer(t, &HTTPServerConfig{
+		Auth:    keys,
+		APIKeys: keys,
+	})
+}
+
+func issueTestKey(t *testing.T, keys *auth.KeyStore, scope domain.Scope) string {
+	t.Helper()
+
+	key, _, err := keys.Issue(string(scope), []domain.Scope{scope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func serveWithKey(server *HttpServer, method string, path string, body string, key string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set(API_KEY_HEADER, key)
+	}
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeAPIKey(t *testing.T, body io.Reader) *generated.ApiKeyResponse {
+	t.Helper()
+
+	var resp generated.ApiKeyResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Key == nil || resp.Value == nil || resp.Value.Id == nil {
+		t.Fatalf("expected the key to be returned, got %+v", resp)
+	}
+	return &resp
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/cors"
+)
+
+// headers browsers may read from cross-origin responses
+var exposedHeaders = []string{
+	REQUEST_ID_HEADER,
+	"Content-Disposition",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"Retry-After",
+}
+
+type CORSConfig struct {
+	// AllowedOrigins may contain one "*" wildcard per origin, like
+	// "http://localhost:*", or be just "*" to allow every origin. CORS
+	// headers are only sent when it is not empty
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+func (c *CORSConfig) enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+func (c *CORSConfig) validate() error {
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		return ErrInvalidCORSConfig
+	}
+	return nil
+}
+
+// corsMiddleware answers preflight requests and adds the CORS headers to
+// every response for an allowed origin
+func corsMiddleware(config *CORSConfig) func(next http.Handler) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowedOrigins:   config.AllowedOrigins,
+		AllowedMethods:   config.AllowedMethods,
+		AllowedHeaders:   append(slices.Clone(config.AllowedHeaders), REQUEST_ID_HEADER, API_KEY_HEADER, NAMESPACE_HEADER),
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: config.AllowCredentials,
+		MaxAge:           int(config.MaxAge.Seconds()),
+	})
+}
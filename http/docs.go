@@ -0,0 +1,77 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DOCS_PATH = "/docs/"
+)
+
+// docsFiles is the interactive docs page. swagger-ui-bundle.js, swagger-ui.css
+// and the favicon are copied unchanged from the dist directory of Swagger UI
+// 5.18.2 (Apache-2.0), so the page works without reaching a CDN. Its LICENSE
+// and NOTICE are kept and served alongside them
+//
+//go:embed docs
+var docsFiles embed.FS
+
+var (
+	openapiYAML = newSpecDocument(openapiSpec, "application/yaml")
+	openapiJSON = newSpecDocument(mustConvertSpecToJSON(openapiSpec), "application/json")
+)
+
+// specDocument serves the spec in one format. Its ETag lets clients that
+// poll for changes to the contract skip downloading it again
+type specDocument struct {
+	body        []byte
+	contentType string
+	etag        string
+}
+
+func newSpecDocument(body []byte, contentType string) *specDocument {
+	sum := sha256.Sum256(body)
+	return &specDocument{
+		body:        body,
+		contentType: contentType,
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+	}
+}
+
+func (d *specDocument) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", d.contentType)
+	w.Header().Set("ETag", d.etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(d.body))
+}
+
+func mustConvertSpecToJSON(spec []byte) []byte {
+	var doc any
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		panic(err)
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Docs serves the interactive API docs under DOCS_PATH
+func Docs() http.Handler {
+	files, err := fs.Sub(docsFiles, "docs")
+	if err != nil {
+		panic(err)
+	}
+
+	return http.StripPrefix(DOCS_PATH, http.FileServer(http.FS(files)))
+}
@@ -0,0 +1,10 @@
+// the spec is loaded relative to this page, so the docs keep working behind a
+// proxy that serves the API under a path prefix
+window.onload = function () {
+  window.ui = SwaggerUIBundle({
+    url: new URL("../openapi.yaml", window.location.href).href,
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis],
+  });
+};
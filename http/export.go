@@ -0,0 +1,177 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/http/generated"
+)
+
+const (
+	EXPORT_FILENAME    = "todos"
+	EXPORT_FLUSH_EVERY = 100
+	EXPORT_PAGE_SIZE   = 500
+	TODOTXT_DATE       = "2006-01-02"
+)
+
+// todoIterator calls fn with every todo to export in turn, stopping at the
+// first error
+type todoIterator func(fn func(i int, todo *generated.Todo) error) error
+
+type exporter struct {
+	contentType string
+	extension   string
+	write       func(w io.Writer, todos todoIterator, flush func()) error
+}
+
+var exporters = map[generated.ExportTodosParamsFormat]exporter{
+	generated.ExportTodosParamsFormatJson:     {"application/json", "json", writeJSONExport},
+	generated.ExportTodosParamsFormatCsv:      {"text/csv; charset=utf-8", "csv", writeCSVExport},
+	generated.ExportTodosParamsFormatMarkdown: {"text/markdown; charset=utf-8", "md", writeMarkdownExport},
+	generated.ExportTodosParamsFormatTodotxt:  {"text/plain; charset=utf-8", "txt", writeTodoTxtExport},
+}
+
+// streamExport writes the todos one at a time as they are read, flushing
+// periodically so neither the todos nor the encoded file are ever held in
+// memory as a whole
+func streamExport(w http.ResponseWriter, e exporter, todos todoIterator) error {
+	w.Header().Add("Content-Type", e.contentType)
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%q", EXPORT_FILENAME+"."+e.extension))
+	w.WriteHeader(http.StatusOK)
+
+	flush := func() {}
+	if flusher, ok := w.(http.Flusher); ok {
+		flush = flusher.Flush
+	}
+
+	return e.write(w, todos, flush)
+}
+
+func writeJSONExport(w io.Writer, todos todoIterator, flush func()) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	err := todos(func(i int, todo *generated.Todo) error {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(todo)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+
+		if i%EXPORT_FLUSH_EVERY == 0 {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
+
+func writeCSVExport(w io.Writer, todos todoIterator, flush func()) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "done", "description", "createdAt", "updatedAt", "doneAt"})
+
+	err := todos(func(i int, todo *generated.Todo) error {
+		cw.Write([]string{
+			todo.Id.String(),
+			strconv.FormatBool(*todo.Done),
+			*todo.Description,
+			formatExportTime(todo.CreatedAt),
+			formatExportTime(todo.UpdatedAt),
+			formatExportTime(todo.DoneAt),
+		})
+
+		if i%EXPORT_FLUSH_EVERY == 0 {
+			cw.Flush()
+			flush()
+			// stop as soon as the client is gone rather than at the end
+			return cw.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeMarkdownExport(w io.Writer, todos todoIterator, flush func()) error {
+	if _, err := io.WriteString(w, "# Todos\n\n"); err != nil {
+		return err
+	}
+
+	return todos(func(i int, todo *generated.Todo) error {
+		check := " "
+		if *todo.Done {
+			check = "x"
+		}
+
+		// keep descriptions on a single list item
+		description := strings.ReplaceAll(*todo.Description, "\n", " ")
+		if _, err := fmt.Fprintf(w, "- [%s] %s\n", check, description); err != nil {
+			return err
+		}
+
+		if i%EXPORT_FLUSH_EVERY == 0 {
+			flush()
+		}
+		return nil
+	})
+}
+
+// writeTodoTxtExport follows http://todotxt.org, keeping the todo id as an
+// `id:` tag so the file can be imported again
+func writeTodoTxtExport(w io.Writer, todos todoIterator, flush func()) error {
+	return todos(func(i int, todo *generated.Todo) error {
+		var line strings.Builder
+		if *todo.Done {
+			line.WriteString("x ")
+			if !todo.DoneAt.IsZero() {
+				line.WriteString(todo.DoneAt.Format(TODOTXT_DATE) + " ")
+			}
+		}
+		if !todo.CreatedAt.IsZero() {
+			line.WriteString(todo.CreatedAt.Format(TODOTXT_DATE) + " ")
+		}
+		line.WriteString(strings.ReplaceAll(*todo.Description, "\n", " "))
+		line.WriteString(" id:" + todo.Id.String() + "\n")
+
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+
+		if i%EXPORT_FLUSH_EVERY == 0 {
+			flush()
+		}
+		return nil
+	})
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -1,6 +1,6 @@
 // Package generated provides primitives to interact with the openapi HTTP API.
 //
-// Code generated by github.com/deepmap/oapi-codegen/v2 version v2.1.0 DO NOT EDIT.
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.4.1 DO NOT EDIT.
 package generated
 
 import (
@@ -9,9 +9,245 @@ import (
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
 
+const (
+	ApiKeyScopes     = "apiKey.Scopes"
+	BearerAuthScopes = "bearerAuth.Scopes"
+)
+
+// Defines values for ChangeResultStatus.
+const (
+	ChangeResultStatusApplied  ChangeResultStatus = "applied"
+	ChangeResultStatusConflict ChangeResultStatus = "conflict"
+	ChangeResultStatusError    ChangeResultStatus = "error"
+)
+
+// Defines values for ClientChangeOp.
+const (
+	Create ClientChangeOp = "create"
+	Delete ClientChangeOp = "delete"
+	Update ClientChangeOp = "update"
+)
+
+// Defines values for HealthStatus.
+const (
+	Down HealthStatus = "down"
+	Up   HealthStatus = "up"
+)
+
+// Defines values for Role.
+const (
+	Editor Role = "editor"
+	Owner  Role = "owner"
+	Viewer Role = "viewer"
+)
+
+// Defines values for Scope.
+const (
+	Admin Scope = "admin"
+	Read  Scope = "read"
+	Write Scope = "write"
+)
+
+// Defines values for ViolationIn.
+const (
+	Body   ViolationIn = "body"
+	Header ViolationIn = "header"
+	Path   ViolationIn = "path"
+	Query  ViolationIn = "query"
+)
+
+// Defines values for ExportFormat.
+const (
+	ExportFormatCsv      ExportFormat = "csv"
+	ExportFormatJson     ExportFormat = "json"
+	ExportFormatMarkdown ExportFormat = "markdown"
+	ExportFormatTodotxt  ExportFormat = "todotxt"
+)
+
+// Defines values for ImportFormat.
+const (
+	ImportFormatCsv     ImportFormat = "csv"
+	ImportFormatJson    ImportFormat = "json"
+	ImportFormatTodotxt ImportFormat = "todotxt"
+)
+
+// Defines values for ExportTodosParamsFormat.
+const (
+	ExportTodosParamsFormatCsv      ExportTodosParamsFormat = "csv"
+	ExportTodosParamsFormatJson     ExportTodosParamsFormat = "json"
+	ExportTodosParamsFormatMarkdown ExportTodosParamsFormat = "markdown"
+	ExportTodosParamsFormatTodotxt  ExportTodosParamsFormat = "todotxt"
+)
+
+// Defines values for ImportTodosParamsFormat.
+const (
+	ImportTodosParamsFormatCsv     ImportTodosParamsFormat = "csv"
+	ImportTodosParamsFormatJson    ImportTodosParamsFormat = "json"
+	ImportTodosParamsFormatTodotxt ImportTodosParamsFormat = "todotxt"
+)
+
+// ApiKey defines model for ApiKey.
+type ApiKey struct {
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	Id        *string    `json:"id,omitempty"`
+	Name      *string    `json:"name,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	Scopes    *[]Scope   `json:"scopes,omitempty"`
+}
+
+// ApiKeyResponse defines model for ApiKeyResponse.
+type ApiKeyResponse struct {
+	// Key The full key to send in the X-API-Key header, only returned when a key is issued or rotated
+	Key   *string `json:"key,omitempty"`
+	Value *ApiKey `json:"value,omitempty"`
+}
+
+// ApiKeysResponse defines model for ApiKeysResponse.
+type ApiKeysResponse struct {
+	Value *[]ApiKey `json:"value,omitempty"`
+}
+
+// ApplyChangesResponse defines model for ApplyChangesResponse.
+type ApplyChangesResponse struct {
+	Value *[]ChangeResult `json:"value,omitempty"`
+}
+
+// Change defines model for Change.
+type Change struct {
+	Deleted *bool  `json:"deleted,omitempty"`
+	Seq     *int64 `json:"seq,omitempty"`
+	Todo    *Todo  `json:"todo,omitempty"`
+}
+
+// ChangeResult defines model for ChangeResult.
+type ChangeResult struct {
+	ClientId *string             `json:"clientId,omitempty"`
+	Current  *Change             `json:"current,omitempty"`
+	Error    *string             `json:"error,omitempty"`
+	Id       *openapi_types.UUID `json:"id,omitempty"`
+	Status   *ChangeResultStatus `json:"status,omitempty"`
+}
+
+// ChangeResultStatus defines model for ChangeResult.Status.
+type ChangeResultStatus string
+
+// ChangesResponse defines model for ChangesResponse.
+type ChangesResponse struct {
+	Token *string   `json:"token,omitempty"`
+	Value *[]Change `json:"value,omitempty"`
+}
+
+// ClientChange defines model for ClientChange.
+type ClientChange struct {
+	// BaseSeq Sequence number of the todo the client last synced, used to detect conflicts
+	BaseSeq *int64 `json:"baseSeq,omitempty"`
+
+	// ClientId Identifier chosen by the client to match a create with its result
+	ClientId *string `json:"clientId,omitempty"`
+
+	// Description What needs doing, which must not be blank
+	Description *Description        `json:"description,omitempty"`
+	Done        *bool               `json:"done,omitempty"`
+	Id          *openapi_types.UUID `json:"id,omitempty"`
+	Op          ClientChangeOp      `json:"op"`
+}
+
+// ClientChangeOp defines model for ClientChange.Op.
+type ClientChangeOp string
+
+// Collaborator defines model for Collaborator.
+type Collaborator struct {
+	AddedAt *time.Time `json:"addedAt,omitempty"`
+	ListId  *string    `json:"listId,omitempty"`
+
+	// Role Viewers may read the todos on a list, editors may also change and delete them, and
+	// owners may also invite and remove collaborators
+	Role   *Role   `json:"role,omitempty"`
+	UserId *string `json:"userId,omitempty"`
+}
+
+// CollaboratorResponse defines model for CollaboratorResponse.
+type CollaboratorResponse struct {
+	Value *Collaborator `json:"value,omitempty"`
+}
+
+// CollaboratorsResponse defines model for CollaboratorsResponse.
+type CollaboratorsResponse struct {
+	Value *[]Collaborator `json:"value,omitempty"`
+}
+
+// Description What needs doing, which must not be blank
+type Description = string
+
 // Error defines model for Error.
 type Error struct {
 	Error *string `json:"error,omitempty"`
+
+	// RequestId The X-Request-ID of the failed request, also sent as a response header
+	RequestId *string `json:"requestId,omitempty"`
+
+	// Violations Each part of the request that does not match this spec, sent with 400 responses
+	Violations *[]Violation `json:"violations,omitempty"`
+}
+
+// HealthCheck defines model for HealthCheck.
+type HealthCheck struct {
+	CheckedAt *time.Time    `json:"checkedAt,omitempty"`
+	Error     *string       `json:"error,omitempty"`
+	LatencyMs *float64      `json:"latencyMs,omitempty"`
+	Name      *string       `json:"name,omitempty"`
+	Status    *HealthStatus `json:"status,omitempty"`
+}
+
+// HealthReport defines model for HealthReport.
+type HealthReport struct {
+	Checks *[]HealthCheck `json:"checks,omitempty"`
+	Status *HealthStatus  `json:"status,omitempty"`
+}
+
+// HealthStatus defines model for HealthStatus.
+type HealthStatus string
+
+// ImportError defines model for ImportError.
+type ImportError struct {
+	Error *string `json:"error,omitempty"`
+
+	// Line Line of the file the row starts on
+	Line *int `json:"line,omitempty"`
+}
+
+// ImportResponse defines model for ImportResponse.
+type ImportResponse struct {
+	DryRun *bool          `json:"dryRun,omitempty"`
+	Errors *[]ImportError `json:"errors,omitempty"`
+
+	// Imported Number of valid rows, which were created unless this was a dry run
+	Imported *int    `json:"imported,omitempty"`
+	Value    *[]Todo `json:"value,omitempty"`
+}
+
+// Invitation defines model for Invitation.
+type Invitation struct {
+	CreatedAt *time.Time          `json:"createdAt,omitempty"`
+	Id        *openapi_types.UUID `json:"id,omitempty"`
+	InvitedBy *string             `json:"invitedBy,omitempty"`
+	ListId    *string             `json:"listId,omitempty"`
+
+	// Role Viewers may read the todos on a list, editors may also change and delete them, and
+	// owners may also invite and remove collaborators
+	Role   *Role   `json:"role,omitempty"`
+	UserId *string `json:"userId,omitempty"`
+}
+
+// InvitationResponse defines model for InvitationResponse.
+type InvitationResponse struct {
+	Value *Invitation `json:"value,omitempty"`
+}
+
+// InvitationsResponse defines model for InvitationsResponse.
+type InvitationsResponse struct {
+	Value *[]Invitation `json:"value,omitempty"`
 }
 
 // MessageResponse defines model for MessageResponse.
@@ -19,6 +255,47 @@ type MessageResponse struct {
 	Message *string `json:"message,omitempty"`
 }
 
+// Namespace defines model for Namespace.
+type Namespace struct {
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	// MaxTodos How many todos the namespace may hold, 0 when unlimited
+	MaxTodos *int    `json:"maxTodos,omitempty"`
+	Name     *string `json:"name,omitempty"`
+}
+
+// NamespaceResponse defines model for NamespaceResponse.
+type NamespaceResponse struct {
+	Value *Namespace `json:"value,omitempty"`
+}
+
+// NamespacesResponse defines model for NamespacesResponse.
+type NamespacesResponse struct {
+	Value *[]Namespace `json:"value,omitempty"`
+}
+
+// Role Viewers may read the todos on a list, editors may also change and delete them, and
+// owners may also invite and remove collaborators
+type Role string
+
+// Scope defines model for Scope.
+type Scope string
+
+// SharedList defines model for SharedList.
+type SharedList struct {
+	ListId *string `json:"listId,omitempty"`
+
+	// Role Viewers may read the todos on a list, editors may also change and delete them, and
+	// owners may also invite and remove collaborators
+	Role  *Role   `json:"role,omitempty"`
+	Todos *[]Todo `json:"todos,omitempty"`
+}
+
+// SharedListsResponse defines model for SharedListsResponse.
+type SharedListsResponse struct {
+	Value *[]SharedList `json:"value,omitempty"`
+}
+
 // Status defines model for Status.
 type Status struct {
 	Status *string `json:"status,omitempty"`
@@ -26,12 +303,17 @@ type Status struct {
 
 // Todo defines model for Todo.
 type Todo struct {
-	CreatedAt   *time.Time          `json:"createdAt,omitempty"`
-	Description *string             `json:"description,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	// Description What needs doing, which must not be blank
+	Description *Description        `json:"description,omitempty"`
 	Done        *bool               `json:"done,omitempty"`
 	DoneAt      *time.Time          `json:"doneAt,omitempty"`
 	Id          *openapi_types.UUID `json:"id,omitempty"`
-	UpdatedAt   *time.Time          `json:"updatedAt,omitempty"`
+
+	// OwnerId Id of the caller that created the todo, empty when authentication is disabled
+	OwnerId   *string    `json:"ownerId,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
 }
 
 // TodoResponse defines model for TodoResponse.
@@ -46,36 +328,210 @@ type TodosResponse struct {
 	Value   *[]Todo `json:"value,omitempty"`
 }
 
+// Violation defines model for Violation.
+type Violation struct {
+	// Field Name of the parameter, or slash separated path to the body property, empty for the whole body
+	Field   string      `json:"field"`
+	In      ViolationIn `json:"in"`
+	Message string      `json:"message"`
+}
+
+// ViolationIn defines model for Violation.In.
+type ViolationIn string
+
+// DryRun defines model for DryRun.
+type DryRun = bool
+
+// ExportFormat defines model for ExportFormat.
+type ExportFormat string
+
+// ImportFormat defines model for ImportFormat.
+type ImportFormat string
+
+// InvitationID defines model for InvitationID.
+type InvitationID = openapi_types.UUID
+
+// KeyID defines model for KeyID.
+type KeyID = string
+
+// ListID defines model for ListID.
+type ListID = string
+
+// NamespaceName defines model for NamespaceName.
+type NamespaceName = string
+
+// SyncToken defines model for SyncToken.
+type SyncToken = string
+
 // TodoID defines model for TodoID.
 type TodoID = openapi_types.UUID
 
+// UserID defines model for UserID.
+type UserID = string
+
+// N400 defines model for 400.
+type N400 = Error
+
+// N401 defines model for 401.
+type N401 = Error
+
+// N403 defines model for 403.
+type N403 = Error
+
+// N404 defines model for 404.
+type N404 = Error
+
+// N409 defines model for 409.
+type N409 = Error
+
+// N413 defines model for 413.
+type N413 = Error
+
+// N429 defines model for 429.
+type N429 = Error
+
 // N500 defines model for 500.
 type N500 = Error
 
+// ApplyChanges defines model for ApplyChanges.
+type ApplyChanges struct {
+	Changes []ClientChange `json:"changes"`
+}
+
+// CreateApiKey defines model for CreateApiKey.
+type CreateApiKey struct {
+	// Name What the key is used for
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+}
+
+// CreateInvitation defines model for CreateInvitation.
+type CreateInvitation struct {
+	// Role Viewers may read the todos on a list, editors may also change and delete them, and
+	// owners may also invite and remove collaborators
+	Role Role `json:"role"`
+
+	// UserId ID of the user to invite, their API key id or token subject
+	UserId string `json:"userId"`
+}
+
+// CreateNamespace defines model for CreateNamespace.
+type CreateNamespace struct {
+	// MaxTodos How many todos the namespace may hold, the server default when omitted
+	MaxTodos *int `json:"maxTodos,omitempty"`
+
+	// Name Lowercase letters, digits and dashes, starting with a letter or digit
+	Name string `json:"name"`
+}
+
 // CreateTodo defines model for CreateTodo.
 type CreateTodo struct {
-	Description *string `json:"description,omitempty"`
+	// Description What needs doing, which must not be blank
+	Description Description `json:"description"`
+	Done        *bool       `json:"done,omitempty"`
 }
 
 // UpdateTodo defines model for UpdateTodo.
 type UpdateTodo struct {
-	Description *string `json:"description,omitempty"`
-	Done        *bool   `json:"done,omitempty"`
+	// Description What needs doing, which must not be blank
+	Description Description `json:"description"`
+	Done        bool        `json:"done"`
+}
+
+// CreateApiKeyJSONBody defines parameters for CreateApiKey.
+type CreateApiKeyJSONBody struct {
+	// Name What the key is used for
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+}
+
+// CreateInvitationJSONBody defines parameters for CreateInvitation.
+type CreateInvitationJSONBody struct {
+	// Role Viewers may read the todos on a list, editors may also change and delete them, and
+	// owners may also invite and remove collaborators
+	Role Role `json:"role"`
+
+	// UserId ID of the user to invite, their API key id or token subject
+	UserId string `json:"userId"`
+}
+
+// CreateNamespaceJSONBody defines parameters for CreateNamespace.
+type CreateNamespaceJSONBody struct {
+	// MaxTodos How many todos the namespace may hold, the server default when omitted
+	MaxTodos *int `json:"maxTodos,omitempty"`
+
+	// Name Lowercase letters, digits and dashes, starting with a letter or digit
+	Name string `json:"name"`
+}
+
+// GetChangesParams defines parameters for GetChanges.
+type GetChangesParams struct {
+	// Since Opaque token returned by the previous sync. Omit it to fetch every todo
+	Since *SyncToken `form:"since,omitempty" json:"since,omitempty"`
+}
+
+// ApplyChangesJSONBody defines parameters for ApplyChanges.
+type ApplyChangesJSONBody struct {
+	Changes []ClientChange `json:"changes"`
 }
 
 // CreateTodoJSONBody defines parameters for CreateTodo.
 type CreateTodoJSONBody struct {
-	Description *string `json:"description,omitempty"`
+	// Description What needs doing, which must not be blank
+	Description Description `json:"description"`
+	Done        *bool       `json:"done,omitempty"`
 }
 
 // UpdateTodoJSONBody defines parameters for UpdateTodo.
 type UpdateTodoJSONBody struct {
-	Description *string `json:"description,omitempty"`
-	Done        *bool   `json:"done,omitempty"`
+	// Description What needs doing, which must not be blank
+	Description Description `json:"description"`
+	Done        bool        `json:"done"`
+}
+
+// ExportTodosParams defines parameters for ExportTodos.
+type ExportTodosParams struct {
+	// Format File format of the export
+	Format *ExportTodosParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+}
+
+// ExportTodosParamsFormat defines parameters for ExportTodos.
+type ExportTodosParamsFormat string
+
+// ImportTodosMultipartBody defines parameters for ImportTodos.
+type ImportTodosMultipartBody struct {
+	File openapi_types.File `json:"file"`
 }
 
+// ImportTodosParams defines parameters for ImportTodos.
+type ImportTodosParams struct {
+	// Format File format of the import. Inferred from the file name when omitted
+	Format *ImportTodosParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+
+	// DryRun Validate the file without creating any todos
+	DryRun *DryRun `form:"dryRun,omitempty" json:"dryRun,omitempty"`
+}
+
+// ImportTodosParamsFormat defines parameters for ImportTodos.
+type ImportTodosParamsFormat string
+
+// CreateApiKeyJSONRequestBody defines body for CreateApiKey for application/json ContentType.
+type CreateApiKeyJSONRequestBody CreateApiKeyJSONBody
+
+// CreateInvitationJSONRequestBody defines body for CreateInvitation for application/json ContentType.
+type CreateInvitationJSONRequestBody CreateInvitationJSONBody
+
+// CreateNamespaceJSONRequestBody defines body for CreateNamespace for application/json ContentType.
+type CreateNamespaceJSONRequestBody CreateNamespaceJSONBody
+
+// ApplyChangesJSONRequestBody defines body for ApplyChanges for application/json ContentType.
+type ApplyChangesJSONRequestBody ApplyChangesJSONBody
+
 // CreateTodoJSONRequestBody defines body for CreateTodo for application/json ContentType.
 type CreateTodoJSONRequestBody CreateTodoJSONBody
 
 // UpdateTodoJSONRequestBody defines body for UpdateTodo for application/json ContentType.
 type UpdateTodoJSONRequestBody UpdateTodoJSONBody
+
+// ImportTodosMultipartRequestBody defines body for ImportTodos for multipart/form-data ContentType.
+type ImportTodosMultipartRequestBody ImportTodosMultipartBody
@@ -3,47 +3,82 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"sync/atomic"
 
+	"github.com/brendenehlers/todo-microservice/auth"
 	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/health"
 	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/brendenehlers/todo-microservice/sharing"
+	"github.com/brendenehlers/todo-microservice/tenant"
+	"github.com/brendenehlers/todo-microservice/tracing"
+	"github.com/go-chi/chi/v5"
 )
 
 type GeneratedTodoRepository interface {
-	CreateTodo(newTodo *generated.CreateTodoJSONRequestBody) (*generated.Todo, error)
-	GetTodo(id *generated.TodoID) (*generated.Todo, error)
-	GetTodos() (*[]generated.Todo, error)
-	UpdateTodo(id *generated.TodoID, update *generated.UpdateTodoJSONRequestBody) (*generated.Todo, error)
-	DeleteTodo(id *generated.TodoID) error
+	CreateTodo(ctx context.Context, newTodo *generated.CreateTodoJSONRequestBody) (*generated.Todo, error)
+	GetTodo(ctx context.Context, id *generated.TodoID) (*generated.Todo, error)
+	GetTodos(ctx context.Context) (*[]generated.Todo, error)
+	ExportTodos(ctx context.Context) (todoIterator, error)
+	UpdateTodo(ctx context.Context, id *generated.TodoID, update *generated.UpdateTodoJSONRequestBody) (*generated.Todo, error)
+	CreateTodos(ctx context.Context, newTodos *[]generated.CreateTodoJSONRequestBody) (*[]generated.Todo, error)
+	SaveTodos(ctx context.Context, writes *[]domain.TodoWrite) (*[]generated.Todo, error)
+	DeleteTodo(ctx context.Context, id *generated.TodoID) error
+	GetChanges(ctx context.Context, since domain.SyncPosition) (*[]generated.Change, domain.SyncPosition, error)
+	ApplyChanges(ctx context.Context, changes *generated.ApplyChangesJSONRequestBody) (*[]generated.ChangeResult, error)
 }
 
 func newAPI(
 	repo GeneratedTodoRepository,
 	log domain.Logger,
+	ready *atomic.Bool,
+	health *health.Registry,
+	keys *auth.KeyStore,
+	namespaces *tenant.Registry,
+	sharing *sharing.Policy,
 ) *api {
 	return &api{
-		repo: repo,
-		log:  log,
+		repo:       repo,
+		log:        log,
+		ready:      ready,
+		health:     health,
+		keys:       keys,
+		namespaces: namespaces,
+		sharing:    sharing,
 	}
 }
 
 type api struct {
-	repo GeneratedTodoRepository
-	log  domain.Logger
+	repo   GeneratedTodoRepository
+	log    domain.Logger
+	ready  *atomic.Bool
+	health *health.Registry
+	// keys is nil unless API key authentication is enabled
+	keys *auth.KeyStore
+	// namespaces is nil unless namespaces are enabled
+	namespaces *tenant.Registry
+	// sharing is nil unless authentication is enabled
+	sharing *sharing.Policy
 }
 
-func (a *api) handler() http.Handler {
-	return generated.Handler(a)
-}
-
-type response struct {
-	val any
-	err error
+func (a *api) handler(middlewares ...generated.MiddlewareFunc) http.Handler {
+	return generated.HandlerWithOptions(a, generated.ChiServerOptions{
+		Middlewares:      middlewares,
+		ErrorHandlerFunc: a.paramError,
+	})
 }
 
-func (*api) GetStatus(w http.ResponseWriter, r *http.Request) {
+func (api *api) GetStatus(w http.ResponseWriter, r *http.Request) {
 	status := "ok"
+	w.Header().Add("Content-Type", "application/json")
+	if !api.ready.Load() {
+		status = "unavailable"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
 	json.NewEncoder(w).Encode(generated.Status{
 		Status: &status,
 	})
@@ -51,165 +86,304 @@ func (*api) GetStatus(w http.ResponseWriter, r *http.Request) {
 
 func (api *api) CreateTodo(w http.ResponseWriter, r *http.Request) {
 	var newTodo generated.CreateTodoJSONRequestBody
-	err := decodeRequestBody(r.Body, &newTodo)
+	err := decodeRequestBody(r.Context(), r.Body, &newTodo)
 	defer r.Body.Close()
 	if err != nil {
-		api.requestError(w, err)
+		api.requestError(w, r, err)
 		return
 	}
 
-	ctx, cancel, respch := processWithTimeout(r.Context(), func(respch chan response) {
-		todo, err := api.repo.CreateTodo(&newTodo)
-		respch <- response{
-			val: todo,
-			err: err,
-		}
-	})
-	defer cancel()
+	todo, err := api.repo.CreateTodo(r.Context(), &newTodo)
+	if err != nil {
+		api.requestError(w, r, err)
+		return
+	}
 
-	select {
-	case <-ctx.Done():
-		api.requestTimeout(w)
+	api.logger(r).Info("Successfully created todo")
+	api.sendTodoResponse(w, todo)
+}
+
+func (api *api) GetTodo(w http.ResponseWriter, r *http.Request, todoId generated.TodoID) {
+	todo, err := api.repo.GetTodo(r.Context(), &todoId)
+	if err != nil {
+		api.requestError(w, r, err)
 		return
-	case resp := <-respch:
-		if resp.err != nil {
-			api.requestError(w, resp.err)
-			return
-		}
+	}
 
-		api.log.Info("Successfully created todo")
-		api.sendTodoResponse(w, resp.val.(*generated.Todo))
+	api.logger(r).Info("Successfully found todo")
+	api.sendTodoResponse(w, todo)
+}
+
+func (api *api) GetTodos(w http.ResponseWriter, r *http.Request) {
+	todos, err := api.repo.GetTodos(r.Context())
+	if err != nil {
+		api.requestError(w, r, err)
 		return
 	}
+
+	api.logger(r).Info("Successfully retrieved todos")
+	api.sendTodosResponse(w, todos)
 }
 
-func (api *api) GetTodo(w http.ResponseWriter, r *http.Request, todoId generated.TodoID) {
-	ctx, cancel, respch := processWithTimeout(r.Context(), func(respch chan response) {
-		val, err := api.repo.GetTodo(&todoId)
-		respch <- response{
-			val: val,
-			err: err,
-		}
-	})
-	defer cancel()
+func (api *api) ExportTodos(w http.ResponseWriter, r *http.Request, params generated.ExportTodosParams) {
+	format := generated.ExportTodosParamsFormatJson
+	if params.Format != nil {
+		format = *params.Format
+	}
 
-	select {
-	case <-ctx.Done():
-		api.requestTimeout(w)
+	e, ok := exporters[format]
+	if !ok {
+		api.badRequest(w, r, ErrInvalidExportFormat)
 		return
-	case resp := <-respch:
-		if resp.err != nil {
-			api.requestError(w, resp.err)
-			return
-		}
+	}
 
-		api.log.Info("Successfully found todo")
-		api.sendTodoResponse(w, resp.val.(*generated.Todo))
+	todos, err := api.repo.ExportTodos(r.Context())
+	if err != nil {
+		api.requestError(w, r, err)
 		return
 	}
+
+	// the headers are already sent once streaming starts, so errors can only be logged
+	if err := streamExport(w, e, todos); err != nil {
+		api.logger(r).Error(err.Error())
+		return
+	}
+
+	api.logger(r).Info("Successfully exported todos")
 }
 
-func (api *api) GetTodos(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel, respch := processWithTimeout(r.Context(), func(respch chan response) {
-		val, err := api.repo.GetTodos()
-		respch <- response{
-			val: val,
-			err: err,
-		}
-	})
-	defer cancel()
+func (api *api) ImportTodos(w http.ResponseWriter, r *http.Request, params generated.ImportTodosParams) {
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_IMPORT_SIZE)
+	file, header, err := r.FormFile(IMPORT_FORM_KEY)
+	if err != nil {
+		api.badRequest(w, r, err)
+		return
+	}
+	defer file.Close()
 
-	select {
-	case <-ctx.Done():
-		api.requestTimeout(w)
+	format, err := importFormat(params.Format, header.Filename)
+	if err != nil {
+		api.badRequest(w, r, err)
 		return
-	case resp := <-respch:
-		if resp.err != nil {
-			api.requestError(w, resp.err)
-			return
-		}
+	}
+
+	parsed, err := importParsers[format](file)
+	if err != nil {
+		api.badRequest(w, r, err)
+		return
+	}
+
+	dryRun := params.DryRun != nil && *params.DryRun
+	imported := len(parsed.todos)
+	result := generated.ImportResponse{
+		DryRun:   &dryRun,
+		Imported: &imported,
+		Errors:   &parsed.errors,
+		Value:    &[]generated.Todo{},
+	}
+	if parsed.errors == nil {
+		result.Errors = &[]generated.ImportError{}
+	}
+
+	if dryRun || imported == 0 {
+		api.logger(r).Info("Successfully validated import")
+		api.sendImportResponse(w, &result)
+		return
+	}
 
-		api.log.Info("Successfully retrieved todos")
-		api.sendTodosResponse(w, resp.val.(*[]generated.Todo))
+	todos, err := api.repo.CreateTodos(r.Context(), &parsed.todos)
+	if err != nil {
+		api.requestError(w, r, err)
+		return
 	}
+
+	api.logger(r).Info("Successfully imported todos")
+	result.Value = todos
+	api.sendImportResponse(w, &result)
 }
 
-func (api *api) UpdateTodo(w http.ResponseWriter, r *http.Request, todoId generated.TodoID) {
-	var todo generated.UpdateTodoJSONRequestBody
-	err := decodeRequestBody(r.Body, &todo)
+func (api *api) GetTodosCalendar(w http.ResponseWriter, r *http.Request) {
+	todos, err := api.repo.GetTodos(r.Context())
 	if err != nil {
-		api.requestError(w, err)
+		api.requestError(w, r, err)
 		return
 	}
 
-	ctx, cancel, respch := processWithTimeout(r.Context(), func(respch chan response) {
-		val, err := api.repo.UpdateTodo(&todoId, &todo)
-		respch <- response{
-			val: val,
-			err: err,
-		}
-	})
-	defer cancel()
+	w.Header().Add("Content-Type", "text/calendar; charset=utf-8")
+	if err := writeCalendar(w, *todos); err != nil {
+		api.logger(r).Error(err.Error())
+		return
+	}
 
-	select {
-	case <-ctx.Done():
-		api.requestTimeout(w)
+	api.logger(r).Info("Successfully retrieved todos calendar")
+}
+
+func (api *api) UploadTodosCalendar(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	calendarTodos, err := parseCalendar(http.MaxBytesReader(w, r.Body, MAX_ICAL_SIZE))
+	if err != nil {
+		api.badRequest(w, r, err)
 		return
-	case resp := <-respch:
-		if resp.err != nil {
-			api.requestError(w, resp.err)
-			return
-		}
+	}
 
-		api.log.Info("Successfully updated todo")
-		api.sendTodoResponse(w, resp.val.(*generated.Todo))
+	todos, err := saveCalendarTodos(r.Context(), api.repo, calendarTodos)
+	if err != nil {
+		api.requestError(w, r, err)
+		return
 	}
+
+	api.logger(r).Info("Successfully saved todos calendar")
+	api.sendTodosResponse(w, todos)
 }
 
-func (api *api) DeleteTodo(w http.ResponseWriter, r *http.Request, todoId generated.TodoID) {
-	ctx, cancel, respch := processWithTimeout(r.Context(), func(respch chan response) {
-		err := api.repo.DeleteTodo(&todoId)
-		respch <- response{
-			err: err,
-		}
-	})
-	defer cancel()
+func (api *api) UpdateTodo(w http.ResponseWriter, r *http.Request, todoId generated.TodoID) {
+	var update generated.UpdateTodoJSONRequestBody
+	err := decodeRequestBody(r.Context(), r.Body, &update)
+	if err != nil {
+		api.requestError(w, r, err)
+		return
+	}
+
+	todo, err := api.repo.UpdateTodo(r.Context(), &todoId, &update)
+	if err != nil {
+		api.requestError(w, r, err)
+		return
+	}
+
+	api.logger(r).Info("Successfully updated todo")
+	api.sendTodoResponse(w, todo)
+}
 
-	select {
-	case <-ctx.Done():
-		api.requestTimeout(w)
+func (api *api) DeleteTodo(w http.ResponseWriter, r *http.Request, todoId generated.TodoID) {
+	err := api.repo.DeleteTodo(r.Context(), &todoId)
+	if err != nil {
+		api.requestError(w, r, err)
 		return
-	case resp := <-respch:
-		if resp.err != nil {
-			api.requestError(w, resp.err)
+	}
+
+	msg := "Successfully deleted todo"
+	api.logger(r).Info(msg)
+	api.requestSuccessWithMessage(w, &msg)
+}
+
+func (api *api) GetChanges(w http.ResponseWriter, r *http.Request, params generated.GetChangesParams) {
+	var since domain.SyncPosition
+	if params.Since != nil {
+		var err error
+		since, err = decodeSyncToken(*params.Since)
+		if err != nil {
+			api.badRequest(w, r, err)
+			return
 		}
+	}
 
-		msg := "Successfully deleted todo"
-		api.log.Info(msg)
-		api.requestSuccessWithMessage(w, &msg)
+	changes, latest, err := api.repo.GetChanges(r.Context(), since)
+	if err != nil {
+		api.requestError(w, r, err)
+		return
 	}
+
+	api.logger(r).Info("Successfully retrieved changes")
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.ChangesResponse{
+		Value: changes,
+		Token: encodeSyncToken(latest),
+	})
 }
 
-func (api *api) requestTimeout(w http.ResponseWriter) {
-	errStr := ErrRequestTimedOut.Error()
-	api.log.Error(ErrRequestTimedOut.Error())
+func (api *api) ApplyChanges(w http.ResponseWriter, r *http.Request) {
+	var changes generated.ApplyChangesJSONRequestBody
+	err := decodeRequestBody(r.Context(), r.Body, &changes)
+	if err != nil {
+		api.requestError(w, r, err)
+		return
+	}
 
-	w.WriteHeader(http.StatusRequestTimeout)
+	results, err := api.repo.ApplyChanges(r.Context(), &changes)
+	if err != nil {
+		api.requestError(w, r, err)
+		return
+	}
+
+	api.logger(r).Info("Successfully applied changes")
 	w.Header().Add("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(generated.Error{
-		Error: &errStr,
+	json.NewEncoder(w).Encode(generated.ApplyChangesResponse{
+		Value: results,
 	})
 }
 
-func (api *api) requestError(w http.ResponseWriter, err error) {
+// logger returns the request's logger with the matched operation and, for
+// operations on a single todo, the todo id added
+func (api *api) logger(r *http.Request) domain.Logger {
+	log := domain.LoggerFromContext(r.Context(), api.log)
+	if op := operationID(r); op != "" {
+		log = log.With("operation", op)
+	}
+	if id := chi.URLParam(r, "todoId"); id != "" {
+		log = log.With("todoId", id)
+	}
+
+	return log
+}
+
+func (api *api) requestError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, domain.ErrTodoNotFound), errors.Is(err, tenant.ErrNamespaceNotFound):
+		api.notFound(w, r, err)
+		return
+	case errors.Is(err, domain.ErrEmptyDescription), errors.Is(err, domain.ErrDescriptionTooLong):
+		api.badRequest(w, r, err)
+		return
+	case errors.Is(err, domain.ErrSyncExpired):
+		api.logger(r).Info(err.Error())
+		sendError(w, r, http.StatusGone, err.Error())
+		return
+	case errors.Is(err, sharing.ErrInsufficientRole):
+		api.logger(r).Warn(err.Error())
+		sendError(w, r, http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, tenant.ErrQuotaExceeded), errors.Is(err, tenant.ErrNamespaceLimit):
+		api.logger(r).Warn(err.Error())
+		sendError(w, r, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
 	errStr := err.Error()
-	api.log.Error(errStr)
+	api.logger(r).Error(errStr)
 
 	w.WriteHeader(http.StatusInternalServerError)
 	w.Header().Add("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(generated.Error{
+	json.NewEncoder(w).Encode(errorResponse(r, errStr))
+}
+
+func (api *api) badRequest(w http.ResponseWriter, r *http.Request, err error) {
+	errStr := err.Error()
+	api.logger(r).Warn(errStr)
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(errorResponse(r, errStr))
+}
+
+func (api *api) notFound(w http.ResponseWriter, r *http.Request, err error) {
+	errStr := err.Error()
+	api.logger(r).Warn(errStr)
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(errorResponse(r, errStr))
+}
+
+// errorResponse includes the request id so clients can quote it when reporting the error
+func errorResponse(r *http.Request, errStr string) generated.Error {
+	resp := generated.Error{
 		Error: &errStr,
-	})
+	}
+	if id := domain.RequestIDFromContext(r.Context()); id != "" {
+		resp.RequestId = &id
+	}
+
+	return resp
 }
 
 func (api *api) sendTodoResponse(w http.ResponseWriter, todo *generated.Todo) {
@@ -226,6 +400,11 @@ func (api *api) sendTodosResponse(w http.ResponseWriter, todos *[]generated.Todo
 	})
 }
 
+func (api *api) sendImportResponse(w http.ResponseWriter, result *generated.ImportResponse) {
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 func (api *api) requestSuccessWithMessage(w http.ResponseWriter, message *string) {
 	w.Header().Add("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(generated.MessageResponse{
@@ -233,17 +412,11 @@ func (api *api) requestSuccessWithMessage(w http.ResponseWriter, message *string
 	})
 }
 
-func decodeRequestBody(r io.ReadCloser, data any) error {
+func decodeRequestBody(ctx context.Context, r io.ReadCloser, data any) error {
+	_, span := tracing.Tracer("http").Start(ctx, "decodeRequestBody")
+	defer span.End()
+
 	err := json.NewDecoder(r).Decode(data)
 	defer r.Close()
 	return err
 }
-
-func processWithTimeout(parentCtx context.Context, fn func(respch chan response)) (context.Context, context.CancelFunc, chan response) {
-	ctx, cancel := context.WithTimeout(parentCtx, REQUEST_TIMEOUT)
-	respch := make(chan response)
-
-	go fn(respch)
-
-	return ctx, cancel, respch
-}
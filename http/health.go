@@ -0,0 +1,76 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/brendenehlers/todo-microservice/health"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+)
+
+const SERVER_CHECK_NAME = "server"
+
+func (api *api) GetLiveness(w http.ResponseWriter, r *http.Request) {
+	status := "ok"
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.Status{
+		Status: &status,
+	})
+}
+
+func (api *api) GetReadiness(w http.ResponseWriter, r *http.Request) {
+	var report *health.Report
+	if api.ready.Load() {
+		report = api.health.Run(r.Context())
+	} else {
+		// dependencies are not checked while the server is starting or
+		// shutting down, traffic should not be routed here either way
+		report = &health.Report{
+			Status: health.STATUS_DOWN,
+			Checks: []health.Result{{
+				Name:   SERVER_CHECK_NAME,
+				Status: health.STATUS_DOWN,
+				Error:  "server is not accepting traffic",
+			}},
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if !report.Up() {
+		api.logger(r).Warn("Readiness check failed", "status", report.Status)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(convertHealthReport(report))
+}
+
+func convertHealthReport(report *health.Report) generated.HealthReport {
+	checks := make([]generated.HealthCheck, 0, len(report.Checks))
+	for _, result := range report.Checks {
+		check := generated.HealthCheck{
+			Name:   &result.Name,
+			Status: healthStatus(result.Status),
+		}
+		if result.Error != "" {
+			check.Error = &result.Error
+		}
+		if !result.CheckedAt.IsZero() {
+			latency := float64(result.Latency.Microseconds()) / 1000
+			check.LatencyMs = &latency
+			check.CheckedAt = &result.CheckedAt
+		}
+		checks = append(checks, check)
+	}
+
+	return generated.HealthReport{
+		Status: healthStatus(report.Status),
+		Checks: &checks,
+	}
+}
+
+func healthStatus(status string) *generated.HealthStatus {
+	s := generated.Down
+	if status == health.STATUS_UP {
+		s = generated.Up
+	}
+	return &s
+}
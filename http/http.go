@@ -2,33 +2,117 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/brendenehlers/todo-microservice/auth"
 	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/graphql"
+	"github.com/brendenehlers/todo-microservice/health"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/brendenehlers/todo-microservice/metrics"
+	"github.com/brendenehlers/todo-microservice/sharing"
+	"github.com/brendenehlers/todo-microservice/tenant"
+	"github.com/brendenehlers/todo-microservice/tracing"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 )
 
 const (
 	REQUEST_TIMEOUT = time.Millisecond * 200
+	// UPLOAD_TIMEOUT covers reading the body of an upload, which takes far
+	// longer than a JSON request for a large file over a slow connection
+	UPLOAD_TIMEOUT  = time.Second * 30
 	DEFAULT_ADDRESS = ":8080"
+
+	GRAPHQL_PATH = "/graphql"
+
+	REQUEST_ID_HEADER     = "X-Request-ID"
+	MAX_REQUEST_ID_LENGTH = 128
 )
 
+// streaming operations write as they go, so they cannot be buffered by the
+// timeout middleware, and uploads need longer to read their body. Probes
+// bound their own checks with the health check timeout
+var defaultRouteTimeouts = map[string]time.Duration{
+	"exportTodos":         0,
+	"importTodos":         UPLOAD_TIMEOUT,
+	"uploadTodosCalendar": UPLOAD_TIMEOUT,
+	"getLiveness":         0,
+	"getReadiness":        0,
+}
+
+// probes must keep working however often they are sent
+var defaultRouteRateLimits = map[string]RateLimit{
+	"getLiveness":  {},
+	"getReadiness": {},
+}
+
 var (
-	ErrRequestTimedOut = fmt.Errorf("request timed out")
-	ErrInvalidRepo     = fmt.Errorf("invalid todo repository")
-	ErrInvalidLogger   = fmt.Errorf("invalid logger")
-	ErrNoPathValue     = fmt.Errorf("no path value found")
+	ErrRequestTimedOut     = fmt.Errorf("request timed out")
+	ErrInvalidRepo         = fmt.Errorf("invalid todo repository")
+	ErrInvalidLogger       = fmt.Errorf("invalid logger")
+	ErrNoPathValue         = fmt.Errorf("no path value found")
+	ErrInvalidSyncToken    = fmt.Errorf("invalid sync token")
+	ErrInvalidChange       = fmt.Errorf("invalid change")
+	ErrInvalidExportFormat = fmt.Errorf("invalid export format")
+	ErrInvalidImportFormat = fmt.Errorf("invalid import format")
+	ErrInvalidImportFile   = fmt.Errorf("invalid import file")
+	ErrInvalidCalendar     = fmt.Errorf("invalid calendar")
+	ErrInvalidCORSConfig   = fmt.Errorf("cors credentials cannot be allowed for every origin")
+	ErrForbidden           = fmt.Errorf("missing required scope")
+	ErrAPIKeysDisabled     = fmt.Errorf("api key authentication is not enabled")
+	ErrNamespacesDisabled  = fmt.Errorf("namespaces are not enabled")
+	ErrSharingDisabled     = fmt.Errorf("sharing requires authentication to be enabled")
+	ErrRateLimited         = fmt.Errorf("rate limit exceeded")
+	ErrTooManyAuthFailures = fmt.Errorf("too many failed authentication attempts")
+	ErrInvalidRequest      = fmt.Errorf("request does not match the api spec")
+	ErrRequestTooLarge     = fmt.Errorf("request body is too large")
 )
 
 type HTTPServerConfig struct {
-	Addr string
-	Repo domain.TodoRepository
-	Ctx  context.Context
-	Log  domain.Logger
+	Addr   string
+	Repo   domain.TodoRepository
+	Events domain.TodoEventSource
+	Ctx    context.Context
+	Log    domain.Logger
+	// Health holds the dependency checks run by the readiness probe
+	Health         *health.Registry
+	Metrics        *metrics.Metrics
+	RequestTimeout time.Duration
+	// RouteTimeouts overrides RequestTimeout for the operationIds it contains
+	RouteTimeouts map[string]time.Duration
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	IdleTimeout   time.Duration
+	// ValidateResponses logs responses that do not match the OpenAPI spec
+	ValidateResponses bool
+	// RateLimit applies to each client of each operation, separately
+	RateLimit RateLimit
+	// RouteRateLimits overrides RateLimit for the operationIds it contains
+	RouteRateLimits map[string]RateLimit
+	// AuthFailureLimit applies to the authentication attempts each IP address
+	// gets rejected, across every operation
+	AuthFailureLimit RateLimit
+	CORS             CORSConfig
+	TLS              TLSConfig
+	// Auth authenticates callers of the operations that require a scope,
+	// every operation is public when it is nil
+	Auth auth.Authenticator
+	// APIKeys backs the key management endpoints when API keys are enabled
+	APIKeys *auth.KeyStore
+	// Namespaces lets requests select a namespace, and backs the namespace
+	// management endpoints. Repo must be backed by it
+	Namespaces *tenant.Registry
+	// Sharing backs the collaborator and invitation endpoints. Repo must be
+	// backed by it for its roles to be enforced
+	Sharing *sharing.Policy
 }
 
 func CreateHTTPServer(config *HTTPServerConfig) (*HttpServer, error) {
@@ -38,6 +122,12 @@ func CreateHTTPServer(config *HTTPServerConfig) (*HttpServer, error) {
 	if config.Log == nil {
 		return nil, ErrInvalidLogger
 	}
+	if err := config.CORS.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.TLS.validate(); err != nil {
+		return nil, err
+	}
 
 	if config.Ctx == nil {
 		config.Ctx = context.Background()
@@ -45,28 +135,140 @@ func CreateHTTPServer(config *HTTPServerConfig) (*HttpServer, error) {
 	if config.Addr == "" {
 		config.Addr = DEFAULT_ADDRESS
 	}
+	if config.RequestTimeout == 0 {
+		config.RequestTimeout = REQUEST_TIMEOUT
+	}
+	if config.Health == nil {
+		config.Health = health.New(health.DEFAULT_CACHE_TTL, health.DEFAULT_CHECK_TIMEOUT)
+	}
+	if config.Metrics == nil {
+		config.Metrics = metrics.New()
+	}
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(RequestID)
+	r.Use(tracing.Middleware(operationName))
+	r.Use(RequestLogger(config.Log))
+	r.Use(config.Metrics.Middleware(operationName))
 	r.Use(middleware.Recoverer)
+	if config.CORS.enabled() {
+		r.Use(corsMiddleware(&config.CORS))
+	}
+	if config.TLS.ClientCAFile != "" {
+		r.Use(requireClientCert(config.Log))
+	}
+	if config.Namespaces != nil {
+		r.Use(SelectNamespace(config.Namespaces, config.Log))
+	}
 
 	repoAdapter := newAdapter(config.Repo)
+	ready := &atomic.Bool{}
+
+	routeTimeouts := make(map[string]time.Duration)
+	for op, timeout := range defaultRouteTimeouts {
+		routeTimeouts[op] = timeout
+	}
+	for op, timeout := range config.RouteTimeouts {
+		routeTimeouts[op] = timeout
+	}
 
 	api := newAPI(
 		repoAdapter,
 		config.Log,
+		ready,
+		config.Health,
+		config.APIKeys,
+		config.Namespaces,
+		config.Sharing,
 	)
-	r.Mount("/", api.handler())
+	routeRateLimits := make(map[string]RateLimit)
+	for op, limit := range defaultRouteRateLimits {
+		routeRateLimits[op] = limit
+	}
+	for op, limit := range config.RouteRateLimits {
+		routeRateLimits[op] = limit
+	}
+
+	requestTimeout := RequestTimeout(config.RequestTimeout, routeTimeouts, config.Log, config.Metrics)
+	rateLimiter := RateLimiter(config.RateLimit, routeRateLimits, config.Log)
+	middlewares := []generated.MiddlewareFunc{
+		// runs last so only authorized requests within their limits are validated
+		ValidateRequests(config.ValidateResponses, config.Log),
+		requestTimeout,
+		// runs after authentication so callers are limited by their principal
+		rateLimiter,
+	}
+	authFailureLimiter := LimitAuthFailures(config.AuthFailureLimit, config.Log)
+	if config.Auth != nil {
+		// added last so they run first, rejecting callers before any other
+		// work, and clients that keep failing before their credentials are checked
+		middlewares = append(middlewares, Authenticate(config.Auth, config.Log), authFailureLimiter)
+	}
+	r.Mount("/", api.handler(middlewares...))
+
+	graphqlHandler, err := graphql.NewHandler(&graphql.GraphQLConfig{
+		Repo:   config.Repo,
+		Events: config.Events,
+		Log:    config.Log,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the GraphQL endpoint shares the limits of the REST operations, apart
+	// from subscriptions, which stream for as long as the client listens
+	timedGraphql := requestTimeout(graphqlHandler)
+	var graphqlRoute http.Handler = rateLimiter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if graphql.Streaming(r) {
+			graphqlHandler.ServeHTTP(w, r)
+			return
+		}
+		timedGraphql.ServeHTTP(w, r)
+	}))
+	if config.Auth != nil {
+		// mutations check for the write scope themselves
+		graphqlRoute = authFailureLimiter(requireScope(config.Auth, domain.ScopeRead, config.Log, graphqlRoute))
+	}
+	r.Handle(GRAPHQL_PATH, graphqlRoute)
+	r.Handle("/graphiql", graphql.GraphiQL())
+	r.Handle("/metrics", config.Metrics.Handler())
+	// the docs bundle is large, so it is compressed for browsers further away
+	// than localhost
+	docs := r.With(middleware.Compress(5))
+	docs.Handle("/openapi.yaml", openapiYAML)
+	docs.Handle("/openapi.json", openapiJSON)
+	docs.Handle(strings.TrimSuffix(DOCS_PATH, "/"), http.RedirectHandler(DOCS_PATH, http.StatusMovedPermanently))
+	docs.Handle(DOCS_PATH+"*", Docs())
 
 	server := &HttpServer{
 		Server: http.Server{
-			Addr:    config.Addr,
-			Handler: r,
+			Addr:         config.Addr,
+			Handler:      r,
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+			IdleTimeout:  config.IdleTimeout,
 			BaseContext: func(l net.Listener) context.Context {
 				return config.Ctx
 			},
 		},
-		log: config.Log,
+		log:   config.Log,
+		ready: ready,
+	}
+
+	if config.TLS.enabled() {
+		server.certs, err = newCertReloader(&config.TLS, config.Log)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = server.certs.tlsConfig()
+		server.stopWatch = make(chan struct{})
+	}
+	if config.TLS.RedirectAddr != "" {
+		server.redirect = &http.Server{
+			Addr:        config.TLS.RedirectAddr,
+			Handler:     redirectHandler(config.Addr),
+			ReadTimeout: config.ReadTimeout,
+			IdleTimeout: config.IdleTimeout,
+		}
 	}
 
 	return server, nil
@@ -74,14 +276,79 @@ func CreateHTTPServer(config *HTTPServerConfig) (*HttpServer, error) {
 
 type HttpServer struct {
 	http.Server
-	log domain.Logger
+	log   domain.Logger
+	ready *atomic.Bool
+	// certs is nil unless the server runs over HTTPS
+	certs *certReloader
+	// redirect is nil unless plain HTTP requests are redirected to HTTPS
+	redirect *http.Server
+	// stopWatch is closed to stop reloading the certificates, once however
+	// often Stop is called
+	stopWatch     chan struct{}
+	stopWatchOnce sync.Once
 }
 
-func (s *HttpServer) Run() {
-	s.log.Info(fmt.Sprintf("Server running on %s", s.Addr))
-	s.ListenAndServe()
+// Run blocks until the server stops, returning nil when it was stopped by Stop
+func (s *HttpServer) Run() error {
+	lis, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+
+	if s.redirect != nil {
+		redirectLis, err := net.Listen("tcp", s.redirect.Addr)
+		if err != nil {
+			lis.Close()
+			return err
+		}
+
+		s.log.Info("Redirecting HTTP to HTTPS", "addr", s.redirect.Addr)
+		go func() {
+			if err := s.redirect.Serve(redirectLis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.Error(err.Error())
+			}
+		}()
+	}
+
+	s.log.Info("Server running", "addr", s.Addr, "tls", s.certs != nil)
+	s.SetReady(true)
+
+	if s.certs != nil {
+		go s.certs.watch(s.stopWatch)
+		err = s.ServeTLS(lis, "", "")
+	} else {
+		err = s.Serve(lis)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// SetReady controls whether the status endpoint reports the server as able to take traffic
+func (s *HttpServer) SetReady(ready bool) {
+	s.ready.Store(ready)
 }
 
-func (s *HttpServer) Stop(ctx context.Context) {
-	s.Shutdown(ctx)
+// Stop waits for in-flight requests to finish, closing any connections
+// still open when ctx is done
+func (s *HttpServer) Stop(ctx context.Context) error {
+	s.SetReady(false)
+
+	if s.stopWatch != nil {
+		s.stopWatchOnce.Do(func() {
+			close(s.stopWatch)
+		})
+	}
+	if s.redirect != nil {
+		// redirects are answered right away, so there is nothing to wait for
+		s.redirect.Close()
+	}
+
+	err := s.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		s.log.Warn("shutdown grace period expired, closing open connections")
+		return s.Close()
+	}
+	return err
 }
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/events"
+	"github.com/brendenehlers/todo-microservice/memory"
+	"github.com/brendenehlers/todo-microservice/slogger"
+)
+
+func TestStopTwice(t *testing.T) {
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := writeTestCertificate(t)
+	repo := events.New(memory.New(log), log)
+	server, err := CreateHTTPServer(&HTTPServerConfig{
+		Addr:   "127.0.0.1:0",
+		Repo:   repo,
+		Events: repo,
+		Log:    log,
+		TLS: TLSConfig{
+			CertFile:       certFile,
+			KeyFile:        keyFile,
+			ReloadInterval: time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errch := make(chan error, 1)
+	go func() {
+		errch <- server.Run()
+	}()
+
+	// the first Stop may come before Run has started serving, which Run
+	// reports as a clean stop either way
+	for range 2 {
+		if err := server.Stop(context.Background()); err != nil {
+			t.Fatalf("unexpected error stopping the server: %s", err)
+		}
+	}
+
+	select {
+	case err := <-errch:
+		if err != nil {
+			t.Fatalf("unexpected error from Run: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Stop")
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for localhost and its
+// key to a temporary directory
+func writeTestCertificate(t *testing.T) (certFile string, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
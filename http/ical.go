@@ -0,0 +1,223 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/google/uuid"
+)
+
+const (
+	ICAL_PRODID      = "-//brendenehlers//todo-microservice//EN"
+	ICAL_DATE_TIME   = "20060102T150405Z"
+	ICAL_LINE_LENGTH = 75
+	MAX_ICAL_SIZE    = 10 << 20
+)
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+var icalUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// calendarTodo is the part of a VTODO that maps onto a todo
+type calendarTodo struct {
+	uid     string
+	summary string
+	done    bool
+}
+
+// writeCalendar renders the todos as VTODO components following RFC 5545
+func writeCalendar(w io.Writer, todos []generated.Todo) error {
+	sort.SliceStable(todos, func(i, j int) bool {
+		return todos[i].CreatedAt.Before(*todos[j].CreatedAt)
+	})
+
+	bw := bufio.NewWriter(w)
+	stamp := time.Now()
+
+	writeICalLine(bw, "BEGIN", "VCALENDAR")
+	writeICalLine(bw, "VERSION", "2.0")
+	writeICalLine(bw, "PRODID", ICAL_PRODID)
+	for _, todo := range todos {
+		writeICalLine(bw, "BEGIN", "VTODO")
+		writeICalLine(bw, "UID", todo.Id.String())
+		writeICalLine(bw, "DTSTAMP", formatICalTime(stamp))
+		writeICalLine(bw, "SUMMARY", icalEscaper.Replace(*todo.Description))
+		writeICalLine(bw, "CREATED", formatICalTime(*todo.CreatedAt))
+		if !todo.UpdatedAt.IsZero() {
+			writeICalLine(bw, "LAST-MODIFIED", formatICalTime(*todo.UpdatedAt))
+		}
+		if *todo.Done {
+			writeICalLine(bw, "STATUS", "COMPLETED")
+			if !todo.DoneAt.IsZero() {
+				writeICalLine(bw, "COMPLETED", formatICalTime(*todo.DoneAt))
+			}
+		} else {
+			writeICalLine(bw, "STATUS", "NEEDS-ACTION")
+		}
+		writeICalLine(bw, "END", "VTODO")
+	}
+	writeICalLine(bw, "END", "VCALENDAR")
+
+	return bw.Flush()
+}
+
+// writeICalLine folds content lines longer than 75 octets without splitting
+// a multi-byte character
+func writeICalLine(w *bufio.Writer, name, value string) {
+	line := name + ":" + value
+	limit := ICAL_LINE_LENGTH
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// continuation lines start with the folding space
+		limit = ICAL_LINE_LENGTH - 1
+	}
+	w.WriteString(line + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func formatICalTime(t time.Time) string {
+	return t.UTC().Format(ICAL_DATE_TIME)
+}
+
+// parseCalendar returns every VTODO in the calendar, ignoring other components
+func parseCalendar(r io.Reader) ([]calendarTodo, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	todos := make([]calendarTodo, 0)
+	var current *calendarTodo
+	depth := 0
+
+	for _, line := range lines {
+		name, value, ok := splitICalLine(line)
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed line %q", ErrInvalidCalendar, line)
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VTODO"):
+			if current != nil {
+				return nil, fmt.Errorf("%w: nested VTODO", ErrInvalidCalendar)
+			}
+			current = &calendarTodo{}
+			depth = 0
+		case current == nil:
+			continue
+		case name == "BEGIN":
+			// nested components such as VALARM
+			depth++
+		case name == "END" && depth > 0:
+			depth--
+		case name == "END" && strings.EqualFold(value, "VTODO"):
+			todos = append(todos, *current)
+			current = nil
+		case depth > 0:
+			continue
+		case name == "UID":
+			current.uid = value
+		case name == "SUMMARY":
+			current.summary = icalUnescaper.Replace(value)
+		case name == "STATUS":
+			current.done = current.done || strings.EqualFold(value, "COMPLETED")
+		case name == "COMPLETED":
+			current.done = true
+		case name == "PERCENT-COMPLETE":
+			current.done = current.done || value == "100"
+		}
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("%w: unterminated VTODO", ErrInvalidCalendar)
+	}
+
+	return todos, nil
+}
+
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	lines := make([]string, 0)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// splitICalLine returns the upper-cased property name, dropping any parameters, and the raw value
+func splitICalLine(line string) (string, string, bool) {
+	sep := strings.IndexAny(line, ":;")
+	if sep < 0 {
+		return "", "", false
+	}
+
+	name := strings.ToUpper(line[:sep])
+	rest := line[sep:]
+	if rest[0] == ';' {
+		// parameter values may contain quoted colons
+		inQuotes := false
+		for i, c := range rest {
+			if c == '"' {
+				inQuotes = !inQuotes
+			}
+			if c == ':' && !inQuotes {
+				return name, rest[i+1:], true
+			}
+		}
+		return "", "", false
+	}
+
+	return name, rest[1:], true
+}
+
+// saveCalendarTodos updates todos whose UID matches an existing todo and
+// creates the rest. Every VTODO is validated before the todos are written in a
+// single batch, so a rejected calendar leaves the todos as they were
+func saveCalendarTodos(ctx context.Context, repo GeneratedTodoRepository, calendarTodos []calendarTodo) (*[]generated.Todo, error) {
+	writes := make([]domain.TodoWrite, 0, len(calendarTodos))
+
+	for _, calTodo := range calendarTodos {
+		description := strings.TrimSpace(calTodo.summary)
+		if err := domain.ValidateDescription(description); err != nil {
+			return nil, err
+		}
+
+		write := domain.TodoWrite{
+			Description: description,
+			Done:        calTodo.done,
+		}
+		// UIDs that are not todo ids came from another calendar
+		if id, err := uuid.Parse(calTodo.uid); err == nil {
+			write.Id = id.String()
+		}
+		writes = append(writes, write)
+	}
+
+	return repo.SaveTodos(ctx, &writes)
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadTodosCalendar(t *testing.T) {
+	server := newTestServer(t)
+
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VTODO",
+		"SUMMARY:first",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"SUMMARY:second",
+		"STATUS:COMPLETED",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n") + "\r\n"
+
+	tests := []struct {
+		name       string
+		body       io.Reader
+		status     int
+		totalTodos int
+	}{
+		{
+			name:       "calendar",
+			body:       strings.NewReader(calendar),
+			status:     http.StatusOK,
+			totalTodos: 2,
+		},
+		{
+			name:       "calendar slower than the request timeout",
+			body:       slowBody([]byte(calendar), 2*REQUEST_TIMEOUT),
+			status:     http.StatusOK,
+			totalTodos: 4,
+		},
+		{
+			name:       "calendar with an empty summary",
+			body:       strings.NewReader(strings.Replace(calendar, "SUMMARY:second", "SUMMARY:", 1)),
+			status:     http.StatusBadRequest,
+			totalTodos: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/todos.ics", tt.body)
+			req.Header.Set("Content-Type", "text/calendar")
+			rec := httptest.NewRecorder()
+			server.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if total := countTodos(t, server); total != tt.totalTodos {
+				t.Errorf("expected %d stored todos, got %d", tt.totalTodos, total)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+)
+
+const (
+	MAX_IMPORT_SIZE = 10 << 20
+	IMPORT_FORM_KEY = "file"
+)
+
+var importExtensions = map[string]generated.ImportTodosParamsFormat{
+	".json": generated.ImportTodosParamsFormatJson,
+	".csv":  generated.ImportTodosParamsFormatCsv,
+	".txt":  generated.ImportTodosParamsFormatTodotxt,
+}
+
+var importParsers = map[generated.ImportTodosParamsFormat]func(r io.Reader) (*importResult, error){
+	generated.ImportTodosParamsFormatJson:    parseJSONImport,
+	generated.ImportTodosParamsFormatCsv:     parseCSVImport,
+	generated.ImportTodosParamsFormatTodotxt: parseTodoTxtImport,
+}
+
+type importResult struct {
+	todos  []generated.CreateTodoJSONRequestBody
+	errors []generated.ImportError
+}
+
+// add validates the row, recording it as an error against line when it is invalid
+func (res *importResult) add(line int, description string, done bool) {
+	description = strings.TrimSpace(description)
+	if err := domain.ValidateDescription(description); err != nil {
+		res.fail(line, err)
+		return
+	}
+
+	res.todos = append(res.todos, generated.CreateTodoJSONRequestBody{
+		Description: description,
+		Done:        &done,
+	})
+}
+
+func (res *importResult) fail(line int, err error) {
+	errStr := err.Error()
+	res.errors = append(res.errors, generated.ImportError{
+		Line:  &line,
+		Error: &errStr,
+	})
+}
+
+// importFormat prefers the explicit format, falling back to the file extension
+func importFormat(format *generated.ImportTodosParamsFormat, filename string) (generated.ImportTodosParamsFormat, error) {
+	if format != nil {
+		if _, ok := importParsers[*format]; ok {
+			return *format, nil
+		}
+		return "", ErrInvalidImportFormat
+	}
+
+	inferred, ok := importExtensions[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return "", ErrInvalidImportFormat
+	}
+
+	return inferred, nil
+}
+
+func parseJSONImport(r io.Reader) (*importResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, ErrInvalidImportFile
+	}
+
+	res := &importResult{}
+	for dec.More() {
+		line := lineAt(data, dec.InputOffset())
+
+		var row struct {
+			Description *string `json:"description"`
+			Done        *bool   `json:"done"`
+		}
+		if err := dec.Decode(&row); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				// the decoder cannot recover from malformed json
+				return nil, fmt.Errorf("%w: %s", ErrInvalidImportFile, err)
+			}
+
+			res.fail(line, fmt.Errorf("invalid value for %s", typeErr.Field))
+			continue
+		}
+
+		res.add(line, valueOrZero(row.Description), valueOrZero(row.Done))
+	}
+
+	return res, nil
+}
+
+func parseCSVImport(r io.Reader) (*importResult, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, ErrInvalidImportFile
+	}
+
+	descriptionCol, doneCol := -1, -1
+	for i, col := range header {
+		switch strings.ToLower(strings.TrimSpace(col)) {
+		case "description":
+			descriptionCol = i
+		case "done":
+			doneCol = i
+		}
+	}
+	if descriptionCol < 0 {
+		return nil, fmt.Errorf("%w: missing description column", ErrInvalidImportFile)
+	}
+
+	res := &importResult{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				res.fail(parseErr.StartLine, parseErr.Err)
+				continue
+			}
+			return nil, err
+		}
+
+		// FieldPos panics unless the last record was read successfully
+		line, _ := cr.FieldPos(0)
+		if descriptionCol >= len(record) {
+			res.fail(line, domain.ErrEmptyDescription)
+			continue
+		}
+
+		done := false
+		if doneCol >= 0 && doneCol < len(record) && strings.TrimSpace(record[doneCol]) != "" {
+			done, err = strconv.ParseBool(strings.TrimSpace(record[doneCol]))
+			if err != nil {
+				res.fail(line, fmt.Errorf("invalid value for done: %q", record[doneCol]))
+				continue
+			}
+		}
+
+		res.add(line, record[descriptionCol], done)
+	}
+
+	return res, nil
+}
+
+// parseTodoTxtImport reads the format described at http://todotxt.org.
+// Priorities, dates and `id:` tags are dropped, projects and contexts are kept
+// as part of the description
+func parseTodoTxtImport(r io.Reader) (*importResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &importResult{}
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		done := false
+		if fields[0] == "x" {
+			done = true
+			fields = fields[1:]
+		}
+		if len(fields) > 0 && isTodoTxtPriority(fields[0]) {
+			fields = fields[1:]
+		}
+		// completion and creation dates
+		for range 2 {
+			if len(fields) > 0 && isTodoTxtDate(fields[0]) {
+				fields = fields[1:]
+			}
+		}
+
+		words := make([]string, 0, len(fields))
+		for _, field := range fields {
+			if !strings.HasPrefix(field, "id:") {
+				words = append(words, field)
+			}
+		}
+
+		res.add(i+1, strings.Join(words, " "), done)
+	}
+
+	return res, nil
+}
+
+func isTodoTxtPriority(field string) bool {
+	return len(field) == 3 && field[0] == '(' && field[1] >= 'A' && field[1] <= 'Z' && field[2] == ')'
+}
+
+func isTodoTxtDate(field string) bool {
+	_, err := time.Parse(TODOTXT_DATE, field)
+	return err == nil
+}
+
+// lineAt returns the line of the first non-whitespace byte at or after offset
+func lineAt(data []byte, offset int64) int {
+	for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n,", rune(data[offset])) {
+		offset++
+	}
+
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
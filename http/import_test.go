@@ -0,0 +1,341 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/events"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/brendenehlers/todo-microservice/memory"
+	"github.com/brendenehlers/todo-microservice/slogger"
+)
+
+type importedTodo struct {
+	description string
+	done        bool
+}
+
+type importFailure struct {
+	line int
+	err  string
+}
+
+func TestImportParsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  generated.ImportTodosParamsFormat
+		file    string
+		todos   []importedTodo
+		errors  []importFailure
+		invalid bool
+	}{
+		{
+			name:   "json",
+			format: generated.ImportTodosParamsFormatJson,
+			file:   "[\n  {\"description\": \"first\"},\n  {\"description\": \" second \", \"done\": true}\n]",
+			todos:  []importedTodo{{"first", false}, {"second", true}},
+		},
+		{
+			name:   "json with invalid rows",
+			format: generated.ImportTodosParamsFormatJson,
+			file:   "[\n  {\"description\": \"\"},\n  {\"description\": \"kept\", \"done\": \"yes\"},\n  {\"description\": \"" + strings.Repeat("a", domain.MAX_DESCRIPTION_LENGTH+1) + "\"},\n  {\"description\": \"last\"}\n]",
+			todos:  []importedTodo{{"last", false}},
+			errors: []importFailure{
+				{2, domain.ErrEmptyDescription.Error()},
+				{3, "invalid value for done"},
+				{4, domain.ErrDescriptionTooLong.Error()},
+			},
+		},
+		{
+			name:    "malformed json",
+			format:  generated.ImportTodosParamsFormatJson,
+			file:    `[{"description": "first"}, {"description": }]`,
+			invalid: true,
+		},
+		{
+			name:    "json that is not a list",
+			format:  generated.ImportTodosParamsFormatJson,
+			file:    `{"description": "first"}`,
+			invalid: true,
+		},
+		{
+			name:   "csv",
+			format: generated.ImportTodosParamsFormatCsv,
+			file:   "done,description\nfalse,first\ntrue,\"second, with a comma\"\n,third\n",
+			todos:  []importedTodo{{"first", false}, {"second, with a comma", true}, {"third", false}},
+		},
+		{
+			name:   "csv with invalid rows",
+			format: generated.ImportTodosParamsFormatCsv,
+			file:   "description,done\n,false\nkept,maybe\nshort\nlast,true\n",
+			todos:  []importedTodo{{"short", false}, {"last", true}},
+			errors: []importFailure{
+				{2, domain.ErrEmptyDescription.Error()},
+				{3, `invalid value for done: "maybe"`},
+			},
+		},
+		{
+			name:   "malformed csv",
+			format: generated.ImportTodosParamsFormatCsv,
+			file:   "description,done\n\"abc\"x,true\nafter,false\n",
+			todos:  []importedTodo{{"after", false}},
+			errors: []importFailure{{2, `extraneous or missing " in quoted-field`}},
+		},
+		{
+			name:    "csv without a description column",
+			format:  generated.ImportTodosParamsFormatCsv,
+			file:    "title,done\nfirst,false\n",
+			invalid: true,
+		},
+		{
+			name:   "todo.txt",
+			format: generated.ImportTodosParamsFormatTodotxt,
+			file:   "(A) 2024-01-02 call mom +family @phone\n\nx 2024-01-03 2024-01-01 pay rent id:123\n",
+			todos:  []importedTodo{{"call mom +family @phone", false}, {"pay rent", true}},
+		},
+		{
+			name:   "todo.txt with invalid lines",
+			format: generated.ImportTodosParamsFormatTodotxt,
+			file:   "first\nx 2024-01-03 id:123\n(B)\n" + strings.Repeat("a", domain.MAX_DESCRIPTION_LENGTH+1) + "\n",
+			todos:  []importedTodo{{"first", false}},
+			errors: []importFailure{
+				{2, domain.ErrEmptyDescription.Error()},
+				{3, domain.ErrEmptyDescription.Error()},
+				{4, domain.ErrDescriptionTooLong.Error()},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := importParsers[tt.format](strings.NewReader(tt.file))
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected the file to be rejected, got %d todos and %d errors", len(res.todos), len(res.errors))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(res.todos) != len(tt.todos) {
+				t.Fatalf("expected %d todos, got %d: %+v", len(tt.todos), len(res.todos), res.todos)
+			}
+			for i, want := range tt.todos {
+				got := res.todos[i]
+				if got.Description != want.description || valueOrZero(got.Done) != want.done {
+					t.Errorf("todo %d: expected %+v, got {description:%s done:%t}", i, want, got.Description, valueOrZero(got.Done))
+				}
+			}
+
+			if len(res.errors) != len(tt.errors) {
+				t.Fatalf("expected %d errors, got %d: %+v", len(tt.errors), len(res.errors), res.errors)
+			}
+			for i, want := range tt.errors {
+				got := res.errors[i]
+				if *got.Line != want.line || !strings.Contains(*got.Error, want.err) {
+					t.Errorf("error %d: expected line %d %q, got line %d %q", i, want.line, want.err, *got.Line, *got.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestImportTodos(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		filename   string
+		file       string
+		status     int
+		imported   int
+		errors     int
+		dryRun     bool
+		totalTodos int
+	}{
+		{
+			name:     "dry run",
+			query:    "?dryRun=true",
+			filename: "todos.csv",
+			file:     "description,done\nfirst,false\n,true\n",
+			status:   http.StatusOK,
+			imported: 1,
+			errors:   1,
+			dryRun:   true,
+		},
+		{
+			name:       "format from the extension",
+			filename:   "todos.txt",
+			file:       "first\nx second\n",
+			status:     http.StatusOK,
+			imported:   2,
+			totalTodos: 2,
+		},
+		{
+			name:       "explicit format",
+			query:      "?format=json",
+			filename:   "upload",
+			file:       `[{"description": "third"}, {"description": ""}]`,
+			status:     http.StatusOK,
+			imported:   1,
+			errors:     1,
+			totalTodos: 3,
+		},
+		{
+			name:       "malformed csv",
+			filename:   "todos.csv",
+			file:       "description\n\"abc\"x\nfourth\n",
+			status:     http.StatusOK,
+			imported:   1,
+			errors:     1,
+			totalTodos: 4,
+		},
+		{
+			name:       "malformed json",
+			filename:   "todos.json",
+			file:       `[{"description": }]`,
+			status:     http.StatusBadRequest,
+			totalTodos: 4,
+		},
+		{
+			name:       "unknown format",
+			filename:   "todos.xml",
+			file:       "<todos/>",
+			status:     http.StatusBadRequest,
+			totalTodos: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			part, err := mw.CreateFormFile(IMPORT_FORM_KEY, tt.filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write([]byte(tt.file))
+			mw.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/todos/import"+tt.query, &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			rec := httptest.NewRecorder()
+			server.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if tt.status == http.StatusOK {
+				var resp generated.ImportResponse
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+				if *resp.Imported != tt.imported || len(*resp.Errors) != tt.errors || *resp.DryRun != tt.dryRun {
+					t.Errorf("expected %d imported, %d errors and dry run %t, got %d, %d and %t",
+						tt.imported, tt.errors, tt.dryRun, *resp.Imported, len(*resp.Errors), *resp.DryRun)
+				}
+				if tt.dryRun && len(*resp.Value) != 0 {
+					t.Errorf("expected a dry run to create no todos, got %d", len(*resp.Value))
+				}
+			}
+
+			if total := countTodos(t, server); total != tt.totalTodos {
+				t.Errorf("expected %d stored todos, got %d", tt.totalTodos, total)
+			}
+		})
+	}
+}
+
+func TestImportSlowUpload(t *testing.T) {
+	server := newTestServer(t)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile(IMPORT_FORM_KEY, "todos.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("first\nx second\n"))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/todos/import", slowBody(body.Bytes(), 2*REQUEST_TIMEOUT))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d for an upload slower than the request timeout, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if total := countTodos(t, server); total != 2 {
+		t.Errorf("expected 2 stored todos, got %d", total)
+	}
+}
+
+// slowBody sends the first half of body right away and the rest after delay,
+// like an upload over a slow connection
+func slowBody(body []byte, delay time.Duration) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		half := len(body) / 2
+		pw.Write(body[:half])
+		time.Sleep(delay)
+		pw.Write(body[half:])
+		pw.Close()
+	}()
+	return pr
+}
+
+func newTestServer(t *testing.T) *HttpServer {
+	t.Helper()
+
+	return newConfiguredTestServer(t, &HTTPServerConfig{})
+}
+
+// newConfiguredTestServer fills in an in-memory repository and a logger, and
+// leaves the rest of config as the test set it
+func newConfiguredTestServer(t *testing.T, config *HTTPServerConfig) *HttpServer {
+	t.Helper()
+
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := events.New(memory.New(log), log)
+	config.Repo = repo
+	config.Events = repo
+	config.Log = log
+	server, err := CreateHTTPServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server
+}
+
+func countTodos(t *testing.T, server *HttpServer) int {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d listing todos, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	var resp generated.TodosResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return len(*resp.Value)
+}
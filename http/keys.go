@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/auth"
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+)
+
+func (api *api) ListApiKeys(w http.ResponseWriter, r *http.Request) {
+	if api.keys == nil {
+		api.notFound(w, r, ErrAPIKeysDisabled)
+		return
+	}
+
+	keys := api.keys.List()
+	value := make([]generated.ApiKey, 0, len(keys))
+	for _, key := range keys {
+		value = append(value, convertAPIKey(&key))
+	}
+
+	api.logger(r).Info("Successfully listed api keys")
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.ApiKeysResponse{
+		Value: &value,
+	})
+}
+
+func (api *api) CreateApiKey(w http.ResponseWriter, r *http.Request) {
+	if api.keys == nil {
+		api.notFound(w, r, ErrAPIKeysDisabled)
+		return
+	}
+
+	var newKey generated.CreateApiKeyJSONRequestBody
+	if err := decodeRequestBody(r.Context(), r.Body, &newKey); err != nil {
+		api.badRequest(w, r, err)
+		return
+	}
+
+	scopes := make([]domain.Scope, 0, len(newKey.Scopes))
+	for _, scope := range newKey.Scopes {
+		scopes = append(scopes, domain.Scope(scope))
+	}
+
+	secret, key, err := api.keys.Issue(newKey.Name, scopes)
+	if err != nil {
+		api.keyError(w, r, err)
+		return
+	}
+
+	api.logger(r).Info("Successfully issued api key", "keyId", key.Id)
+	api.sendAPIKeyResponse(w, key, secret)
+}
+
+func (api *api) RevokeApiKey(w http.ResponseWriter, r *http.Request, keyId generated.KeyID) {
+	if api.keys == nil {
+		api.notFound(w, r, ErrAPIKeysDisabled)
+		return
+	}
+
+	key, err := api.keys.Revoke(keyId)
+	if err != nil {
+		api.keyError(w, r, err)
+		return
+	}
+
+	api.logger(r).Info("Successfully revoked api key", "keyId", key.Id)
+	api.sendAPIKeyResponse(w, key, "")
+}
+
+func (api *api) RotateApiKey(w http.ResponseWriter, r *http.Request, keyId generated.KeyID) {
+	if api.keys == nil {
+		api.notFound(w, r, ErrAPIKeysDisabled)
+		return
+	}
+
+	secret, key, err := api.keys.Rotate(keyId)
+	if err != nil {
+		api.keyError(w, r, err)
+		return
+	}
+
+	api.logger(r).Info("Successfully rotated api key", "keyId", key.Id)
+	api.sendAPIKeyResponse(w, key, secret)
+}
+
+func (api *api) keyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		api.notFound(w, r, err)
+	case errors.Is(err, auth.ErrEmptyKeyName),
+		errors.Is(err, auth.ErrNoScopes),
+		errors.Is(err, auth.ErrInvalidScope),
+		errors.Is(err, auth.ErrKeyRevoked):
+		api.badRequest(w, r, err)
+	default:
+		api.requestError(w, r, err)
+	}
+}
+
+func (api *api) sendAPIKeyResponse(w http.ResponseWriter, key *auth.APIKey, secret string) {
+	value := convertAPIKey(key)
+	resp := generated.ApiKeyResponse{
+		Value: &value,
+	}
+	if secret != "" {
+		resp.Key = &secret
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func convertAPIKey(key *auth.APIKey) generated.ApiKey {
+	scopes := make([]generated.Scope, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, generated.Scope(scope))
+	}
+
+	return generated.ApiKey{
+		Id:        &key.Id,
+		Name:      &key.Name,
+		Scopes:    &scopes,
+		CreatedAt: &key.CreatedAt,
+		RotatedAt: timeOrNil(key.RotatedAt),
+		RevokedAt: timeOrNil(key.RevokedAt),
+	}
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -1,22 +1,205 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"net/http"
+	"sync"
 	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/brendenehlers/todo-microservice/metrics"
+	"github.com/go-chi/chi/middleware"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// TODO finish this
-func RequestTimeout(timeout time.Duration, handler http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		// ctx, cancel := context.WithTimeout(r.Context(), timeout)
-		// defer cancel()
+// RequestTimeout gives each operation a context deadline, using the timeout in
+// routeTimeouts for its operationId or fallback otherwise. The response is
+// buffered so a handler still running after the deadline cannot write once the
+// timeout error has been sent. A timeout of 0 disables the middleware for an
+// operation, which streaming operations need
+func RequestTimeout(fallback time.Duration, routeTimeouts map[string]time.Duration, log domain.Logger, m *metrics.Metrics) generated.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			op := operationID(r)
+			timeout, ok := routeTimeouts[op]
+			if !ok {
+				timeout = fallback
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{
+				header: make(http.Header),
+			}
+			done := make(chan struct{})
+			panicch := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicch <- p
+					}
+				}()
+
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicch:
+				// re-panic on the request goroutine so the recoverer middleware sees it
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				dst := w.Header()
+				for k, v := range tw.header {
+					dst[k] = v
+				}
+				if tw.status == 0 {
+					tw.status = http.StatusOK
+				}
+				w.WriteHeader(tw.status)
+				w.Write(tw.buf.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+
+				errStr := ErrRequestTimedOut.Error()
+				domain.LoggerFromContext(r.Context(), log).Error(errStr, "operation", op, "timeout", timeout)
+				m.RequestTimedOut(op)
+
+				w.Header().Add("Content-Type", "application/json")
+				w.WriteHeader(http.StatusRequestTimeout)
+				json.NewEncoder(w).Encode(errorResponse(r, errStr))
+			}
+		}
 
-		// go handler.ServeHTTP(w, r)
+		return http.HandlerFunc(fn)
+	}
+}
 
-		// <-ctx.Done()
-		// fmt.Println("context reacted")
-		// cancel()
+// RequestID tags every request with the id in its X-Request-ID header, or a
+// new one if the header is missing or unusable, and echoes it in the response
+func RequestID(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(REQUEST_ID_HEADER)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(REQUEST_ID_HEADER, id)
+		next.ServeHTTP(w, r.WithContext(domain.ContextWithRequestID(r.Context(), id)))
 	}
 
 	return http.HandlerFunc(fn)
 }
+
+// validRequestID rejects ids that are too long or could forge log output
+func validRequestID(id string) bool {
+	if id == "" || len(id) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestLogger logs every completed request, at warn level for client errors
+// and error level for server errors. Handlers get a logger carrying the
+// request id, method, path and trace id from the request context
+func RequestLogger(log domain.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			reqLog := log.With(
+				"requestId", domain.RequestIDFromContext(r.Context()),
+				"method", r.Method,
+				"path", r.URL.Path,
+			)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				reqLog = reqLog.With("traceId", sc.TraceID().String())
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				args := []any{
+					"operation", operationName(r),
+					"status", status,
+					"bytes", ww.BytesWritten(),
+					"duration", time.Since(start),
+				}
+				switch {
+				case status >= http.StatusInternalServerError:
+					reqLog.Error("Request completed", args...)
+				case status >= http.StatusBadRequest:
+					reqLog.Warn("Request completed", args...)
+				default:
+					reqLog.Info("Request completed", args...)
+				}
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(domain.ContextWithLogger(r.Context(), reqLog)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// timeoutWriter holds the response until the handler finishes, rejecting
+// writes once the request has timed out
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+
+	tw.status = status
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/brendenehlers/todo-microservice/tenant"
+)
+
+const (
+	NAMESPACE_HEADER      = "X-Namespace"
+	NAMESPACE_PATH_PREFIX = "/ns/"
+)
+
+// SelectNamespace puts the namespace chosen by the /ns/{namespace} path
+// prefix, or else the X-Namespace header, on the request context. The prefix
+// is stripped so the rest of the path routes as usual
+func SelectNamespace(namespaces *tenant.Registry, log domain.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			name := r.Header.Get(NAMESPACE_HEADER)
+			if rest, ok := strings.CutPrefix(r.URL.Path, NAMESPACE_PATH_PREFIX); ok {
+				name, rest, _ = strings.Cut(rest, "/")
+				r.URL.Path = "/" + rest
+				r.URL.RawPath = ""
+			}
+			if name == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			namespace, err := namespaces.Lookup(name)
+			if err != nil {
+				domain.LoggerFromContext(r.Context(), log).Warn(err.Error(), "namespace", name)
+				sendError(w, r, http.StatusNotFound, err.Error())
+				return
+			}
+
+			ctx := domain.ContextWithNamespace(r.Context(), namespace)
+			reqLog := domain.LoggerFromContext(ctx, log).With("namespace", name)
+			next.ServeHTTP(w, r.WithContext(domain.ContextWithLogger(ctx, reqLog)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func (api *api) ListNamespaces(w http.ResponseWriter, r *http.Request) {
+	if api.namespaces == nil {
+		api.notFound(w, r, ErrNamespacesDisabled)
+		return
+	}
+
+	namespaces := api.namespaces.List()
+	value := make([]generated.Namespace, 0, len(namespaces))
+	for _, ns := range namespaces {
+		value = append(value, convertNamespace(&ns))
+	}
+
+	api.logger(r).Info("Successfully listed namespaces")
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.NamespacesResponse{
+		Value: &value,
+	})
+}
+
+func (api *api) CreateNamespace(w http.ResponseWriter, r *http.Request) {
+	if api.namespaces == nil {
+		api.notFound(w, r, ErrNamespacesDisabled)
+		return
+	}
+
+	var newNamespace generated.CreateNamespaceJSONRequestBody
+	if err := decodeRequestBody(r.Context(), r.Body, &newNamespace); err != nil {
+		api.badRequest(w, r, err)
+		return
+	}
+
+	ns, err := api.namespaces.Create(newNamespace.Name, valueOrZero(newNamespace.MaxTodos))
+	if err != nil {
+		api.namespaceError(w, r, err)
+		return
+	}
+
+	api.logger(r).Info("Successfully created namespace", "namespace", ns.Name)
+	api.sendNamespaceResponse(w, ns)
+}
+
+func (api *api) DeleteNamespace(w http.ResponseWriter, r *http.Request, namespace generated.NamespaceName) {
+	if api.namespaces == nil {
+		api.notFound(w, r, ErrNamespacesDisabled)
+		return
+	}
+
+	if err := api.namespaces.Delete(namespace); err != nil {
+		api.namespaceError(w, r, err)
+		return
+	}
+
+	msg := "Successfully deleted namespace"
+	api.logger(r).Info(msg, "namespace", namespace)
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.MessageResponse{
+		Message: &msg,
+	})
+}
+
+func (api *api) ResetNamespace(w http.ResponseWriter, r *http.Request, namespace generated.NamespaceName) {
+	if api.namespaces == nil {
+		api.notFound(w, r, ErrNamespacesDisabled)
+		return
+	}
+
+	ns, err := api.namespaces.Reset(namespace)
+	if err != nil {
+		api.namespaceError(w, r, err)
+		return
+	}
+
+	api.logger(r).Info("Successfully reset namespace", "namespace", ns.Name)
+	api.sendNamespaceResponse(w, ns)
+}
+
+func (api *api) namespaceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, tenant.ErrInvalidNamespace),
+		errors.Is(err, tenant.ErrDefaultNamespace),
+		errors.Is(err, tenant.ErrInvalidQuota):
+		api.badRequest(w, r, err)
+	case errors.Is(err, tenant.ErrNamespaceAlreadyExists):
+		api.logger(r).Warn(err.Error())
+		sendError(w, r, http.StatusConflict, err.Error())
+	default:
+		api.requestError(w, r, err)
+	}
+}
+
+func (api *api) sendNamespaceResponse(w http.ResponseWriter, ns *tenant.Namespace) {
+	value := convertNamespace(ns)
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.NamespaceResponse{
+		Value: &value,
+	})
+}
+
+func convertNamespace(ns *tenant.Namespace) generated.Namespace {
+	return generated.Namespace{
+		Name:      &ns.Name,
+		MaxTodos:  &ns.MaxTodos,
+		CreatedAt: &ns.CreatedAt,
+	}
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed openapi.yaml
+var openapiSpec []byte
+
+// GRAPHQL_OPERATION names requests to the GraphQL endpoint, which is outside
+// the spec, so its timeout and rate limit are configured like an operation's
+const GRAPHQL_OPERATION = "graphql"
+
+// operations maps "METHOD /path/{param}" to the operationId in the spec, so
+// middleware can be configured per operation
+var operations = mustLoadOperations(openapiSpec)
+
+func mustLoadOperations(spec []byte) map[string]string {
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `yaml:"operationId"`
+		} `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		panic(err)
+	}
+
+	ops := map[string]string{
+		http.MethodGet + " " + GRAPHQL_PATH:  GRAPHQL_OPERATION,
+		http.MethodPost + " " + GRAPHQL_PATH: GRAPHQL_OPERATION,
+	}
+	for path, methods := range doc.Paths {
+		for method, op := range methods {
+			if op.OperationID != "" {
+				ops[strings.ToUpper(method)+" "+path] = op.OperationID
+			}
+		}
+	}
+
+	return ops
+}
+
+// operationID returns the operationId of the route chi matched for r, or an
+// empty string for routes outside the spec
+func operationID(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+
+	return operations[r.Method+" "+rctx.RoutePattern()]
+}
+
+// operationName names requests in metrics and traces by operationId, falling
+// back to the route pattern for routes outside the spec so cardinality stays bounded
+func operationName(r *http.Request) string {
+	if op := operationID(r); op != "" {
+		return op
+	}
+
+	// unknown paths only match the "/*" mount of the REST API
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.RoutePattern() == "" || rctx.RoutePattern() == "/*" {
+		return "unmatched"
+	}
+	return rctx.RoutePattern()
+}
@@ -0,0 +1,216 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/go-chi/chi/v5/middleware"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// idle buckets are dropped this often, once they have refilled
+	RATE_LIMIT_SWEEP_INTERVAL = time.Minute
+)
+
+// RateLimit is a token bucket refilled at Rate requests per second and
+// holding at most Burst of them. A zero Rate disables the limit
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l RateLimit) enabled() bool {
+	return l.Rate > 0
+}
+
+// RateLimiter limits how often each client may call each operation. Clients
+// are told apart by their principal once they are authenticated, or else their
+// IP address, so it must run after authentication
+func RateLimiter(fallback RateLimit, routeLimits map[string]RateLimit, log domain.Logger) generated.MiddlewareFunc {
+	buckets := newRateBuckets()
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			op := operationID(r)
+			limit, ok := routeLimits[op]
+			if !ok {
+				limit = fallback
+			}
+			if !limit.enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := time.Now()
+			allowed, remaining, retryAfter, reset := buckets.take(bucketKey{op, clientKey(r)}, limit, now)
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			h.Set("RateLimit-Reset", seconds(reset))
+			if !allowed {
+				domain.LoggerFromContext(r.Context(), log).Warn(ErrRateLimited.Error(), "operation", op)
+				h.Set("Retry-After", seconds(retryAfter))
+				sendError(w, r, http.StatusTooManyRequests, ErrRateLimited.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// LimitAuthFailures turns away IP addresses that keep failing authentication
+// before their credentials are checked again. Only rejected attempts spend
+// from the limit, so clients sending valid credentials never run it down. It
+// must run before authentication, which RateLimiter cannot
+func LimitAuthFailures(limit RateLimit, log domain.Logger) generated.MiddlewareFunc {
+	buckets := newRateBuckets()
+
+	return func(next http.Handler) http.Handler {
+		if !limit.enabled() {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			// public operations do not authenticate, so there is nothing to fail
+			if _, ok := r.Context().Value(generated.ApiKeyScopes).([]string); !ok && operationID(r) != GRAPHQL_OPERATION {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := bucketKey{client: "ip:" + remoteIP(r)}
+			if retryAfter, ok := buckets.exhausted(key, limit, time.Now()); ok {
+				domain.LoggerFromContext(r.Context(), log).Warn(ErrTooManyAuthFailures.Error())
+				w.Header().Set("Retry-After", seconds(retryAfter))
+				sendError(w, r, http.StatusTooManyRequests, ErrTooManyAuthFailures.Error())
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			if ww.Status() == http.StatusUnauthorized {
+				buckets.take(key, limit, time.Now())
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+type bucketKey struct {
+	operation string
+	client    string
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type rateBuckets struct {
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+func newRateBuckets() *rateBuckets {
+	return &rateBuckets{
+		buckets:   make(map[bucketKey]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// exhausted reports whether the client's bucket is out of tokens, and how long
+// until the next one, without spending any
+func (rb *rateBuckets) exhausted(key bucketKey, limit RateLimit, now time.Time) (time.Duration, bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	b, ok := rb.buckets[key]
+	if !ok {
+		return 0, false
+	}
+
+	tokens := b.limiter.TokensAt(now)
+	if tokens >= 1 {
+		return 0, false
+	}
+	return untilTokens(1-tokens, limit.Rate), true
+}
+
+// take spends a token from the client's bucket if one is left, reporting the
+// tokens remaining, how long until the next token and until the bucket is full
+func (rb *rateBuckets) take(key bucketKey, limit RateLimit, now time.Time) (allowed bool, remaining int, retryAfter time.Duration, reset time.Duration) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if now.Sub(rb.lastSweep) > RATE_LIMIT_SWEEP_INTERVAL {
+		rb.sweep(now)
+	}
+
+	b, ok := rb.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)}
+		rb.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	allowed = b.limiter.AllowN(now, 1)
+	tokens := b.limiter.TokensAt(now)
+	if !allowed {
+		retryAfter = untilTokens(1-tokens, limit.Rate)
+	}
+
+	return allowed, int(math.Max(0, math.Floor(tokens))), retryAfter, untilTokens(float64(limit.Burst)-tokens, limit.Rate)
+}
+
+// sweep drops the buckets that have refilled since they were last used, as a
+// new bucket would behave the same. It must be called with the lock held
+func (rb *rateBuckets) sweep(now time.Time) {
+	for key, b := range rb.buckets {
+		if b.limiter.TokensAt(now) >= float64(b.limiter.Burst()) {
+			delete(rb.buckets, key)
+		}
+	}
+	rb.lastSweep = now
+}
+
+func untilTokens(tokens float64, perSecond float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / perSecond * float64(time.Second))
+}
+
+// seconds rounds up, so clients waiting that long are never early
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// clientKey identifies the caller of r. Credentials that were not verified
+// are ignored, as anyone could send a new one with every request to get a
+// fresh bucket
+func clientKey(r *http.Request) string {
+	if principal := domain.PrincipalFromContext(r.Context()); principal != nil {
+		return "principal:" + principal.Id
+	}
+	return "ip:" + remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/auth"
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+func TestRateLimiter(t *testing.T) {
+	server := newConfiguredTestServer(t, &HTTPServerConfig{
+		RateLimit: RateLimit{Rate: 1, Burst: 5},
+		RouteRateLimits: map[string]RateLimit{
+			"getTodos":   {Rate: 1, Burst: 2},
+			"createTodo": {},
+		},
+	})
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		ip        string
+		status    int
+		limit     string
+		remaining string
+	}{
+		{name: "first request", method: http.MethodGet, path: "/todos", ip: "192.0.2.1", status: http.StatusOK, limit: "2", remaining: "1"},
+		{name: "last request of the burst", method: http.MethodGet, path: "/todos", ip: "192.0.2.1", status: http.StatusOK, limit: "2", remaining: "0"},
+		{name: "over the route limit", method: http.MethodGet, path: "/todos", ip: "192.0.2.1", status: http.StatusTooManyRequests, limit: "2", remaining: "0"},
+		{name: "another client", method: http.MethodGet, path: "/todos", ip: "192.0.2.2", status: http.StatusOK, limit: "2", remaining: "1"},
+		{name: "another operation", method: http.MethodGet, path: "/todos/export", ip: "192.0.2.1", status: http.StatusOK, limit: "5", remaining: "4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveFrom(server, tt.method, tt.path, "", tt.ip)
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+
+			h := rec.Header()
+			if h.Get("RateLimit-Limit") != tt.limit || h.Get("RateLimit-Remaining") != tt.remaining {
+				t.Errorf("expected a limit of %s with %s remaining, got %s with %s", tt.limit, tt.remaining, h.Get("RateLimit-Limit"), h.Get("RateLimit-Remaining"))
+			}
+			if h.Get("RateLimit-Reset") == "" {
+				t.Error("expected the RateLimit-Reset header")
+			}
+			if limited := h.Get("Retry-After") != ""; limited != (tt.status == http.StatusTooManyRequests) {
+				t.Errorf("expected Retry-After only on limited requests, got %q", h.Get("Retry-After"))
+			}
+		})
+	}
+
+	// a rate of 0 disables the limit, and the probes are never limited
+	for _, path := range []string{"/todo", "/healthz"} {
+		method := http.MethodGet
+		if path == "/todo" {
+			method = http.MethodPost
+		}
+		for range 10 {
+			rec := serveFrom(server, method, path, "", "192.0.2.1")
+			if rec.Code == http.StatusTooManyRequests || rec.Header().Get("RateLimit-Limit") != "" {
+				t.Fatalf("expected %s %s not to be limited, got status %d", method, path, rec.Code)
+			}
+		}
+	}
+}
+
+func TestRateBucketsRefill(t *testing.T) {
+	buckets := newRateBuckets()
+	limit := RateLimit{Rate: 2, Burst: 2}
+	key := bucketKey{operation: "getTodos", client: "ip:192.0.2.1"}
+	now := time.Now()
+
+	buckets.take(key, limit, now)
+	allowed, remaining, _, reset := buckets.take(key, limit, now)
+	if !allowed || remaining != 0 || reset != time.Second {
+		t.Fatalf("expected the burst to be spent and full again in a second, got allowed %t, %d remaining, reset %s", allowed, remaining, reset)
+	}
+
+	allowed, _, retryAfter, _ := buckets.take(key, limit, now)
+	if allowed || retryAfter != 500*time.Millisecond {
+		t.Fatalf("expected to wait for the next token, got allowed %t, retry after %s", allowed, retryAfter)
+	}
+
+	if allowed, _, _, _ := buckets.take(key, limit, now.Add(500*time.Millisecond)); !allowed {
+		t.Error("expected a token to refill at the rate")
+	}
+
+	// refilled buckets are swept, as a new bucket would behave the same
+	buckets.take(bucketKey{client: "ip:192.0.2.2"}, limit, now.Add(time.Hour))
+	if _, ok := buckets.buckets[key]; ok {
+		t.Error("expected the refilled bucket to be swept")
+	}
+}
+
+func TestLimitAuthFailures(t *testing.T) {
+	keys := auth.NewKeyStore()
+	server := newConfiguredTestServer(t, &HTTPServerConfig{
+		Auth:             keys,
+		AuthFailureLimit: RateLimit{Rate: 0.01, Burst: 2},
+	})
+	key := issueTestKey(t, keys, domain.ScopeRead)
+
+	// valid credentials never run the limit down
+	for range 5 {
+		if rec := serveFrom(server, http.MethodGet, "/todos", key, "192.0.2.1"); rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+	}
+
+	for range 2 {
+		if rec := serveFrom(server, http.MethodGet, "/todos", "wrong", "192.0.2.2"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		key    string
+		ip     string
+		status int
+	}{
+		{name: "another failure", path: "/todos", key: "wrong", ip: "192.0.2.2", status: http.StatusTooManyRequests},
+		{name: "valid key from the same address", path: "/todos", key: key, ip: "192.0.2.2", status: http.StatusTooManyRequests},
+		{name: "graphql from the same address", path: GRAPHQL_PATH, key: key, ip: "192.0.2.2", status: http.StatusTooManyRequests},
+		{name: "public operation", path: "/healthz", ip: "192.0.2.2", status: http.StatusOK},
+		{name: "another address", path: "/todos", key: "wrong", ip: "192.0.2.3", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveFrom(server, http.MethodGet, tt.path, tt.key, tt.ip)
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if tt.status == http.StatusTooManyRequests && (rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), ErrTooManyAuthFailures.Error())) {
+				t.Errorf("expected a Retry-After and %q, got %v: %s", ErrTooManyAuthFailures, rec.Header(), rec.Body)
+			}
+		})
+	}
+}
+
+func serveFrom(server *HttpServer, method string, path string, key string, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if method == http.MethodPost {
+		req = httptest.NewRequest(method, path, strings.NewReader(`{"description": "rate limited"}`))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set(API_KEY_HEADER, key)
+	}
+	req.RemoteAddr = ip + ":1234"
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/brendenehlers/todo-microservice/sharing"
+	"github.com/google/uuid"
+)
+
+func (api *api) ListCollaborators(w http.ResponseWriter, r *http.Request, listId generated.ListID) {
+	if api.sharing == nil {
+		api.notFound(w, r, ErrSharingDisabled)
+		return
+	}
+
+	collaborators, err := api.sharing.Collaborators(r.Context(), listId)
+	if err != nil {
+		api.sharingError(w, r, err)
+		return
+	}
+
+	value := make([]generated.Collaborator, 0, len(collaborators))
+	for _, c := range collaborators {
+		value = append(value, convertCollaborator(&c))
+	}
+
+	api.logger(r).Info("Successfully listed collaborators", "listId", listId)
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.CollaboratorsResponse{
+		Value: &value,
+	})
+}
+
+func (api *api) RemoveCollaborator(w http.ResponseWriter, r *http.Request, listId generated.ListID, userId generated.UserID) {
+	if api.sharing == nil {
+		api.notFound(w, r, ErrSharingDisabled)
+		return
+	}
+
+	if err := api.sharing.RemoveCollaborator(r.Context(), listId, userId); err != nil {
+		api.sharingError(w, r, err)
+		return
+	}
+
+	msg := "Successfully removed collaborator"
+	api.logger(r).Info(msg, "listId", listId, "userId", userId)
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.MessageResponse{
+		Message: &msg,
+	})
+}
+
+func (api *api) CreateInvitation(w http.ResponseWriter, r *http.Request, listId generated.ListID) {
+	if api.sharing == nil {
+		api.notFound(w, r, ErrSharingDisabled)
+		return
+	}
+
+	var newInvitation generated.CreateInvitationJSONRequestBody
+	if err := decodeRequestBody(r.Context(), r.Body, &newInvitation); err != nil {
+		api.badRequest(w, r, err)
+		return
+	}
+
+	inv, err := api.sharing.Invite(r.Context(), listId, newInvitation.UserId, domain.Role(newInvitation.Role))
+	if err != nil {
+		api.sharingError(w, r, err)
+		return
+	}
+
+	api.logger(r).Info("Successfully created invitation", "listId", listId, "invitationId", inv.Id)
+	value := convertInvitation(inv)
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.InvitationResponse{
+		Value: &value,
+	})
+}
+
+func (api *api) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	if api.sharing == nil {
+		api.notFound(w, r, ErrSharingDisabled)
+		return
+	}
+
+	invitations, err := api.sharing.Invitations(r.Context())
+	if err != nil {
+		api.sharingError(w, r, err)
+		return
+	}
+
+	value := make([]generated.Invitation, 0, len(invitations))
+	for _, inv := range invitations {
+		value = append(value, convertInvitation(&inv))
+	}
+
+	api.logger(r).Info("Successfully listed invitations")
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.InvitationsResponse{
+		Value: &value,
+	})
+}
+
+func (api *api) AcceptInvitation(w http.ResponseWriter, r *http.Request, invitationId generated.InvitationID) {
+	if api.sharing == nil {
+		api.notFound(w, r, ErrSharingDisabled)
+		return
+	}
+
+	collaborator, err := api.sharing.Accept(r.Context(), invitationId.String())
+	if err != nil {
+		api.sharingError(w, r, err)
+		return
+	}
+
+	api.logger(r).Info("Successfully accepted invitation", "invitationId", invitationId.String(), "listId", collaborator.ListId)
+	value := convertCollaborator(collaborator)
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.CollaboratorResponse{
+		Value: &value,
+	})
+}
+
+func (api *api) GetSharedWithMe(w http.ResponseWriter, r *http.Request) {
+	if api.sharing == nil {
+		api.notFound(w, r, ErrSharingDisabled)
+		return
+	}
+
+	lists, err := api.sharing.SharedWithMe(r.Context())
+	if err != nil {
+		api.sharingError(w, r, err)
+		return
+	}
+
+	value := make([]generated.SharedList, 0, len(lists))
+	for _, list := range lists {
+		todos := make([]generated.Todo, 0, len(list.Todos))
+		for _, todo := range list.Todos {
+			gTodo, err := covertDomainTodoToGeneratedTodo(&todo)
+			if err != nil {
+				api.requestError(w, r, err)
+				return
+			}
+			todos = append(todos, *gTodo)
+		}
+
+		value = append(value, generated.SharedList{
+			ListId: &list.ListId,
+			Role:   (*generated.Role)(&list.Role),
+			Todos:  &todos,
+		})
+	}
+
+	api.logger(r).Info("Successfully retrieved shared lists")
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generated.SharedListsResponse{
+		Value: &value,
+	})
+}
+
+func (api *api) sharingError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, sharing.ErrListNotFound),
+		errors.Is(err, sharing.ErrInvitationNotFound),
+		errors.Is(err, sharing.ErrCollaboratorMissing):
+		api.notFound(w, r, err)
+	case errors.Is(err, sharing.ErrInvalidRole),
+		errors.Is(err, sharing.ErrEmptyUserId),
+		errors.Is(err, sharing.ErrInviteSelf),
+		errors.Is(err, sharing.ErrUnauthenticated):
+		api.badRequest(w, r, err)
+	default:
+		api.requestError(w, r, err)
+	}
+}
+
+func convertCollaborator(c *domain.Collaborator) generated.Collaborator {
+	return generated.Collaborator{
+		ListId:  &c.ListId,
+		UserId:  &c.UserId,
+		Role:    (*generated.Role)(&c.Role),
+		AddedAt: &c.AddedAt,
+	}
+}
+
+func convertInvitation(inv *domain.Invitation) generated.Invitation {
+	id, _ := uuid.Parse(inv.Id)
+	return generated.Invitation{
+		Id:        &id,
+		ListId:    &inv.ListId,
+		UserId:    &inv.UserId,
+		Role:      (*generated.Role)(&inv.Role),
+		InvitedBy: &inv.InvitedBy,
+		CreatedAt: &inv.CreatedAt,
+	}
+}
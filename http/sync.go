@@ -0,0 +1,42 @@
+package http
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+const (
+	SYNC_TOKEN_PREFIX = "seq:"
+)
+
+// sync tokens are opaque to clients so the change log representation can
+// change without breaking them. Tokens from before the epoch was added decode
+// with an empty epoch, so they expire rather than fail
+func encodeSyncToken(position domain.SyncPosition) *string {
+	raw := SYNC_TOKEN_PREFIX + strconv.FormatUint(position.Seq, 10) + ":" + position.Epoch
+	token := base64.RawURLEncoding.EncodeToString([]byte(raw))
+	return &token
+}
+
+func decodeSyncToken(token string) (domain.SyncPosition, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return domain.SyncPosition{}, ErrInvalidSyncToken
+	}
+
+	position, ok := strings.CutPrefix(string(raw), SYNC_TOKEN_PREFIX)
+	if !ok {
+		return domain.SyncPosition{}, ErrInvalidSyncToken
+	}
+	seq, epoch, _ := strings.Cut(position, ":")
+
+	val, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return domain.SyncPosition{}, ErrInvalidSyncToken
+	}
+
+	return domain.SyncPosition{Epoch: epoch, Seq: val}, nil
+}
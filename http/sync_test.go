@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/brendenehlers/todo-microservice/http/generated"
+)
+
+func TestGetChanges(t *testing.T) {
+	server := newTestServer(t)
+
+	createTestTodo(t, server, "first")
+	_, token := getChanges(t, server, "", http.StatusOK)
+	createTestTodo(t, server, "second")
+
+	changes, next := getChanges(t, server, token, http.StatusOK)
+	if len(changes) != 1 || *changes[0].Todo.Description != "second" {
+		t.Errorf("expected only the second todo to have changed, got %+v", changes)
+	}
+
+	position, err := decodeSyncToken(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	position.Seq++
+	ahead := *encodeSyncToken(position)
+
+	tests := []struct {
+		name   string
+		server *HttpServer
+		token  string
+		status int
+	}{
+		{
+			name:   "latest token",
+			server: server,
+			token:  next,
+			status: http.StatusOK,
+		},
+		{
+			name:   "token from before a restart",
+			server: newTestServer(t),
+			token:  next,
+			status: http.StatusGone,
+		},
+		{
+			name:   "token ahead of the store",
+			server: server,
+			token:  ahead,
+			status: http.StatusGone,
+		},
+		{
+			name:   "token without an epoch",
+			server: server,
+			token:  base64.RawURLEncoding.EncodeToString([]byte(SYNC_TOKEN_PREFIX + "1")),
+			status: http.StatusGone,
+		},
+		{
+			name:   "malformed token",
+			server: server,
+			token:  base64.RawURLEncoding.EncodeToString([]byte("not a token")),
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, _ := getChanges(t, tt.server, tt.token, tt.status)
+			if tt.status == http.StatusOK && len(changes) != 0 {
+				t.Errorf("expected no changes, got %+v", changes)
+			}
+		})
+	}
+}
+
+func createTestTodo(t *testing.T, server *HttpServer, description string) {
+	t.Helper()
+
+	body := strings.NewReader(`{"description": "` + description + `"}`)
+	req := httptest.NewRequest(http.MethodPost, "/todo", body)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d creating a todo, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+}
+
+// getChanges syncs with token, or fetches every todo when it is empty, and
+// returns the changes and next token of a successful sync
+func getChanges(t *testing.T, server *HttpServer, token string, status int) ([]generated.Change, string) {
+	t.Helper()
+
+	target := "/sync"
+	if token != "" {
+		target += "?since=" + url.QueryEscape(token)
+	}
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, rec.Code, rec.Body)
+	}
+	if status != http.StatusOK {
+		return nil, ""
+	}
+
+	var resp generated.ChangesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return *resp.Value, *resp.Token
+}
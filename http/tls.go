@@ -0,0 +1,234 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+const (
+	HTTPS_PORT = "443"
+)
+
+var (
+	ErrInvalidTLSConfig = fmt.Errorf("tls needs both a certificate and a key file")
+	ErrTLSRequired      = fmt.Errorf("client certificates and https redirects need tls to be enabled")
+	ErrInvalidClientCA  = fmt.Errorf("client ca file contains no certificates")
+	ErrClientCertNeeded = fmt.Errorf("a client certificate is required")
+)
+
+// the probes and metrics are called by infrastructure that rarely holds a
+// client certificate, so they are served without one
+var clientCertExemptPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// TLSConfig serves the API over HTTPS when CertFile and KeyFile are set
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a CA bundle every client must present a certificate
+	// signed by, so only other services holding one can call the API. The
+	// probes and metrics are served without one
+	ClientCAFile string
+	// ReloadInterval is how often the files are checked for changes, so
+	// renewed certificates are picked up without a restart. 0 disables reloading
+	ReloadInterval time.Duration
+	// RedirectAddr serves a plain HTTP listener that redirects every request
+	// to HTTPS, disabled when empty
+	RedirectAddr string
+}
+
+func (c *TLSConfig) enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+func (c *TLSConfig) validate() error {
+	if c.enabled() && (c.CertFile == "" || c.KeyFile == "") {
+		return ErrInvalidTLSConfig
+	}
+	if !c.enabled() && (c.ClientCAFile != "" || c.RedirectAddr != "") {
+		return ErrTLSRequired
+	}
+	return nil
+}
+
+// certReloader serves the certificate and client CAs last loaded from the
+// files in its config, reloading them whenever the files change
+type certReloader struct {
+	config  *TLSConfig
+	log     domain.Logger
+	current atomic.Pointer[certificates]
+	// modTimes holds the modification time of each file when it was last
+	// loaded. Only the watch goroutine touches it once the reloader is created
+	modTimes map[string]time.Time
+}
+
+type certificates struct {
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// newCertReloader loads the files in config, failing if they are unusable
+func newCertReloader(config *TLSConfig, log domain.Logger) (*certReloader, error) {
+	c := &certReloader{
+		config:   config,
+		log:      log,
+		modTimes: make(map[string]time.Time),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *certReloader) files() []string {
+	files := []string{c.config.CertFile, c.config.KeyFile}
+	if c.config.ClientCAFile != "" {
+		files = append(files, c.config.ClientCAFile)
+	}
+	return files
+}
+
+func (c *certReloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range c.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.config.CertFile, c.config.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	certs := &certificates{cert: &cert}
+	if c.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		certs.clientCAs = x509.NewCertPool()
+		if !certs.clientCAs.AppendCertsFromPEM(pem) {
+			return ErrInvalidClientCA
+		}
+	}
+
+	c.current.Store(certs)
+	c.modTimes = modTimes
+	return nil
+}
+
+// changed reports whether any file was modified since it was last loaded
+func (c *certReloader) changed() bool {
+	for _, file := range c.files() {
+		info, err := os.Stat(file)
+		// a file missing for a moment while it is replaced counts as a change,
+		// and the failed load is retried on the next check
+		if err != nil || !info.ModTime().Equal(c.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch reloads the files whenever they change until done is closed. A failed
+// reload keeps the current certificates, so a half-written renewal does not
+// take the server down
+func (c *certReloader) watch(done <-chan struct{}) {
+	if c.config.ReloadInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.config.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+			if err := c.load(); err != nil {
+				c.log.Error("Failed to reload TLS certificates, keeping the current ones", "error", err)
+				continue
+			}
+			c.log.Info("Reloaded TLS certificates")
+		}
+	}
+}
+
+// tlsConfig builds the config of every connection from the current
+// certificates, so a reload applies to the next handshake
+func (c *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certs := c.current.Load()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*certs.cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			// certificates are verified when presented and required by
+			// requireClientCert, so the exempt paths can be reached without one
+			if certs.clientCAs != nil {
+				config.ClientCAs = certs.clientCAs
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+			return config, nil
+		},
+	}
+}
+
+// requireClientCert rejects requests that did not present a client
+// certificate, apart from those to the exempt paths. The handshake has already
+// verified any certificate that was presented against the client CAs
+func requireClientCert(log domain.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if clientCertExemptPaths[r.URL.Path] || (r.TLS != nil && len(r.TLS.VerifiedChains) > 0) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			domain.LoggerFromContext(r.Context(), log).Warn(ErrClientCertNeeded.Error())
+			sendError(w, r, http.StatusForbidden, ErrClientCertNeeded.Error())
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// redirectHandler sends every request to the same host and path over HTTPS,
+// on the port of httpsAddr. 308 keeps the method and body of the request
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != HTTPS_PORT {
+			host = net.JoinHostPort(host, port)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
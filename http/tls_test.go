@@ -0,0 +1,179 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/slogger"
+)
+
+func TestCertReloader(t *testing.T) {
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writeTestCertificate(t)
+	certs, err := newCertReloader(&TLSConfig{CertFile: certFile, KeyFile: keyFile}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := certs.current.Load()
+
+	if certs.changed() {
+		t.Fatal("expected the files just loaded to be unchanged")
+	}
+
+	// renew the certificate in place, as a certificate manager would
+	renewedCert, renewedKey := writeTestCertificate(t)
+	for src, dst := range map[string]string{renewedCert: certFile, renewedKey: keyFile} {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dst, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(dst, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !certs.changed() {
+		t.Fatal("expected the renewed files to be reported changed")
+	}
+	if err := certs.load(); err != nil {
+		t.Fatal(err)
+	}
+	if certs.current.Load() == first || certs.changed() {
+		t.Error("expected the renewed certificate to be loaded")
+	}
+
+	// a file missing while it is replaced counts as a change, and the failed
+	// load keeps the current certificate
+	renewed := certs.current.Load()
+	if err := os.Remove(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if !certs.changed() {
+		t.Error("expected a missing file to be reported changed")
+	}
+	if err := certs.load(); err == nil {
+		t.Error("expected loading a missing key to fail")
+	}
+	if certs.current.Load() != renewed {
+		t.Error("expected the failed load to keep the current certificate")
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	clientCertFile, clientKeyFile := writeTestCertificate(t)
+	server := newConfiguredTestServer(t, &HTTPServerConfig{
+		TLS: TLSConfig{
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			// the client certificate is self-signed, so it is its own CA
+			ClientCAFile: clientCertFile,
+		},
+	})
+	ts := httptest.NewUnstartedServer(server.Handler)
+	ts.TLS = server.TLSConfig
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	trusted, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrusted, err := tls.LoadX509KeyPair(writeTestCertificate(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cert *tls.Certificate
+		path string
+		// status is 0 when the handshake should fail
+		status int
+	}{
+		{name: "trusted certificate", cert: &trusted, path: "/todos", status: http.StatusOK},
+		{name: "no certificate", path: "/todos", status: http.StatusForbidden},
+		{name: "untrusted certificate", cert: &untrusted, path: "/todos"},
+		{name: "liveness probe without a certificate", path: "/healthz", status: http.StatusOK},
+		{name: "readiness probe without a certificate", path: "/readyz", status: http.StatusServiceUnavailable},
+		{name: "metrics without a certificate", path: "/metrics", status: http.StatusOK},
+		{name: "untrusted certificate to a probe", cert: &untrusted, path: "/healthz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tlsTestClient(t, certFile, tt.cert).Get(ts.URL + tt.path)
+			if tt.status == 0 {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("expected the handshake to fail, got status %d", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		target    string
+		expected  string
+	}{
+		{name: "default port", httpsAddr: ":443", target: "http://example.com/todos?limit=5", expected: "https://example.com/todos?limit=5"},
+		{name: "other port", httpsAddr: ":8443", target: "http://example.com:8080/todo/1", expected: "https://example.com:8443/todo/1"},
+		{name: "ipv6 host", httpsAddr: ":8443", target: "http://[::1]:8080/todos", expected: "https://[::1]:8443/todos"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			redirectHandler(tt.httpsAddr).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Errorf("expected status %d, got %d", http.StatusPermanentRedirect, rec.Code)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expected {
+				t.Errorf("expected a redirect to %q, got %q", tt.expected, location)
+			}
+		})
+	}
+}
+
+// tlsTestClient trusts the self-signed server certificate and presents cert,
+// when it is set
+func tlsTestClient(t *testing.T, serverCertFile string, cert *tls.Certificate) *http.Client {
+	t.Helper()
+
+	pem, err := os.ReadFile(serverCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pem)
+
+	config := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
@@ -0,0 +1,265 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/http/generated"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// JSON bodies are small, so anything larger is rejected before it is read
+	// into memory for validation. File uploads are bounded by their handlers
+	MAX_JSON_BODY_SIZE = 1 << 20
+	JSON_CONTENT_TYPE  = "application/json"
+)
+
+// validationRoutes maps each operationId to its route in the spec
+var validationRoutes = mustLoadValidationRoutes(openapiSpec)
+
+type validationRoute struct {
+	route *routers.Route
+	// jsonBody is set for operations that take a JSON body. Other bodies,
+	// like uploaded files, are left for their handlers to parse and check
+	jsonBody bool
+}
+
+func mustLoadValidationRoutes(spec []byte) map[string]*validationRoute {
+	// ids are parsed with the same package the generated handlers use
+	openapi3.DefineStringFormatCallback("uuid", func(s string) error {
+		_, err := uuid.Parse(s)
+		return err
+	})
+
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	routes := make(map[string]*validationRoute)
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			routes[op.OperationID] = &validationRoute{
+				route: &routers.Route{
+					Spec:      doc,
+					Path:      path,
+					PathItem:  item,
+					Method:    method,
+					Operation: op,
+				},
+				jsonBody: op.RequestBody != nil && op.RequestBody.Value.Content.Get(JSON_CONTENT_TYPE) != nil,
+			}
+		}
+	}
+
+	return routes
+}
+
+// ValidateRequests rejects requests whose parameters or JSON body do not match
+// the OpenAPI spec with a 400 listing every violation. Callers are checked by
+// the Authenticate middleware, not here. When validateResponses is set, JSON
+// responses that do not match the spec are logged, which is meant for
+// development as it buffers every JSON response
+func ValidateRequests(validateResponses bool, log domain.Logger) generated.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			vr, ok := validationRoutes[operationID(r)]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if vr.jsonBody {
+				r.Body = http.MaxBytesReader(w, r.Body, MAX_JSON_BODY_SIZE)
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams(r),
+				Route:      vr.route,
+				Options: &openapi3filter.Options{
+					ExcludeRequestBody: !vr.jsonBody,
+					MultiError:         true,
+					// security is enforced by the Authenticate middleware
+					AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+				},
+			}
+			if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				invalidRequest(w, r, log, err)
+				return
+			}
+
+			if !validateResponses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			body := &jsonBodyRecorder{header: ww.Header()}
+			ww.Tee(body)
+			next.ServeHTTP(ww, r)
+
+			if body.buf.Len() == 0 {
+				return
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			err := openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 status,
+				Header:                 ww.Header(),
+				Body:                   io.NopCloser(&body.buf),
+				Options: &openapi3filter.Options{
+					IncludeResponseStatus: true,
+					MultiError:            true,
+				},
+			})
+			if err != nil {
+				domain.LoggerFromContext(r.Context(), log).Error("Response does not match the api spec", "status", status, "error", err)
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// invalidRequest sends the violations in err, or a 413 if the body was too
+// large to be validated
+func invalidRequest(w http.ResponseWriter, r *http.Request, log domain.Logger, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		domain.LoggerFromContext(r.Context(), log).Warn(ErrRequestTooLarge.Error(), "limit", maxBytesErr.Limit)
+		sendError(w, r, http.StatusRequestEntityTooLarge, ErrRequestTooLarge.Error())
+		return
+	}
+
+	violations := make([]generated.Violation, 0)
+	collectViolations(err, "", "", &violations)
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].In != violations[j].In {
+			return violations[i].In < violations[j].In
+		}
+		return violations[i].Field < violations[j].Field
+	})
+
+	sendViolations(w, r, domain.LoggerFromContext(r.Context(), log), violations)
+}
+
+// paramError reports the parameters the generated handlers fail to parse, which
+// happens before ValidateRequests runs, in the same shape as its violations
+func (api *api) paramError(w http.ResponseWriter, r *http.Request, err error) {
+	var name string
+	switch e := err.(type) {
+	case *generated.InvalidParamFormatError:
+		name, err = e.ParamName, e.Err
+	case *generated.UnmarshalingParamError:
+		name, err = e.ParamName, e.Err
+	case *generated.RequiredParamError:
+		name = e.ParamName
+	case *generated.RequiredHeaderError:
+		name = e.ParamName
+	case *generated.TooManyValuesForParamError:
+		name = e.ParamName
+	}
+
+	in := generated.Query
+	if vr, ok := validationRoutes[operationID(r)]; ok {
+		for _, param := range vr.route.Operation.Parameters {
+			if param.Value.Name == name {
+				in = generated.ViolationIn(param.Value.In)
+			}
+		}
+	}
+
+	sendViolations(w, r, api.logger(r), []generated.Violation{{In: in, Field: name, Message: err.Error()}})
+}
+
+func sendViolations(w http.ResponseWriter, r *http.Request, log domain.Logger, violations []generated.Violation) {
+	errStr := ErrInvalidRequest.Error()
+	log.Warn(errStr, "violations", len(violations))
+
+	resp := errorResponse(r, errStr)
+	resp.Violations = &violations
+
+	w.Header().Add("Content-Type", JSON_CONTENT_TYPE)
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// collectViolations flattens the errors returned by the validator, naming each
+// one after the parameter or body property it concerns
+func collectViolations(err error, in generated.ViolationIn, field string, violations *[]generated.Violation) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, inner := range e {
+			collectViolations(inner, in, field, violations)
+		}
+	case *openapi3filter.RequestError:
+		switch {
+		case e.Parameter != nil:
+			in, field = generated.ViolationIn(e.Parameter.In), e.Parameter.Name
+		case e.RequestBody != nil:
+			in = generated.Body
+		}
+		if e.Err == nil {
+			*violations = append(*violations, generated.Violation{In: in, Field: field, Message: e.Reason})
+			return
+		}
+		collectViolations(e.Err, in, field, violations)
+	case *openapi3.SchemaError:
+		if path := strings.Join(e.JSONPointer(), "/"); path != "" {
+			if field != "" {
+				field += "/"
+			}
+			field += path
+		}
+		*violations = append(*violations, generated.Violation{In: in, Field: field, Message: e.Reason})
+	default:
+		*violations = append(*violations, generated.Violation{In: in, Field: field, Message: err.Error()})
+	}
+}
+
+// pathParams returns the path parameters chi matched for r
+func pathParams(r *http.Request) map[string]string {
+	params := make(map[string]string)
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return params
+	}
+
+	for i, key := range rctx.URLParams.Keys {
+		params[key] = rctx.URLParams.Values[i]
+	}
+	return params
+}
+
+// jsonBodyRecorder keeps a copy of the response body when it is JSON, so
+// streamed exports in other formats are not held in memory
+type jsonBodyRecorder struct {
+	header http.Header
+	buf    bytes.Buffer
+}
+
+func (b *jsonBodyRecorder) Write(p []byte) (int, error) {
+	mediaType, _, _ := mime.ParseMediaType(b.header.Get("Content-Type"))
+	if mediaType == JSON_CONTENT_TYPE {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
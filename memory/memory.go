@@ -1,36 +1,174 @@
 package memory
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/brendenehlers/todo-microservice/domain"
 	"github.com/google/uuid"
 )
 
+const (
+	// TOMBSTONE_RETENTION is how long deletions stay in the change log.
+	// Clients that last synced before the oldest deletion kept have to fetch
+	// every todo again
+	TOMBSTONE_RETENTION = 7 * 24 * time.Hour
+)
+
 var (
-	ErrTodoDoesNotExist  = fmt.Errorf("todo does not exist")
+	ErrTodoDoesNotExist  = domain.ErrTodoNotFound
 	ErrTodoAlreadyExists = fmt.Errorf("todo already exists")
 	ErrInvalidParameter  = fmt.Errorf("invalid function parameters")
 )
 
 func New(log domain.Logger) *InMemoryTodoRepository {
 	return &InMemoryTodoRepository{
-		todos: make(map[string]*domain.Todo),
-		log:   log,
+		todos:     make(map[string]*domain.Todo),
+		owners:    make(map[string]map[string]struct{}),
+		epoch:     uuid.New().String(),
+		retention: TOMBSTONE_RETENTION,
+		log:       log,
 	}
 }
 
 type InMemoryTodoRepository struct {
+	mu    sync.RWMutex
 	todos map[string]*domain.Todo
+	// owners indexes the ids of the todos of each owner
+	owners map[string]map[string]struct{}
+	// tombstones holds the deletions of the last retention, in the order
+	// they were made
+	tombstones []tombstone
+	retention  time.Duration
+	// horizon is the sequence number of the latest pruned tombstone. The
+	// changes after any earlier sequence number are no longer all recorded
+	horizon uint64
+	// epoch tells the change log of this store apart from the logs of earlier
+	// stores, whose sequence numbers started over at 0 just the same
+	epoch string
+	seq   uint64
 	log   domain.Logger
 }
 
-func (r *InMemoryTodoRepository) CreateTodo(newTodo *domain.NewTodo) (*domain.Todo, error) {
+type tombstone struct {
+	id        string
+	seq       uint64
+	ownerId   string
+	deletedAt time.Time
+}
+
+func (r *InMemoryTodoRepository) CreateTodo(ctx context.Context, newTodo *domain.NewTodo) (*domain.Todo, error) {
 	if newTodo == nil {
 		return nil, ErrInvalidParameter
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerId, _ := domain.OwnerFromContext(ctx)
+	todo, err := r.createTodo(ownerId, newTodo)
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger(ctx).Debug("Stored todo", "todoId", todo.Id, "seq", todo.Seq)
+	return copyTodo(todo), nil
+}
+
+func (r *InMemoryTodoRepository) CreateTodos(ctx context.Context, newTodos *[]domain.NewTodo) (*[]domain.Todo, error) {
+	if newTodos == nil {
+		return nil, ErrInvalidParameter
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerId, _ := domain.OwnerFromContext(ctx)
+	todos := make([]domain.Todo, 0, len(*newTodos))
+	for _, newTodo := range *newTodos {
+		todo, err := r.createTodo(ownerId, &newTodo)
+		if err != nil {
+			// roll back the todos already created in this batch
+			for _, created := range todos {
+				r.removeTodo(&created)
+			}
+			return nil, err
+		}
+		todos = append(todos, *todo)
+	}
+
+	r.logger(ctx).Debug("Stored todos", "count", len(todos), "seq", r.seq)
+	return &todos, nil
+}
+
+func (r *InMemoryTodoRepository) SaveTodos(ctx context.Context, writes *[]domain.TodoWrite) (*[]domain.Todo, error) {
+	if writes == nil {
+		return nil, ErrInvalidParameter
+	}
+	for _, write := range *writes {
+		if err := domain.ValidateDescription(write.Description); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerId, all := domain.OwnerFromContext(ctx)
+
+	// create the todos first, as only they can fail, so there are no
+	// updates to undo when rolling back
+	saved := make([]*domain.Todo, len(*writes))
+	created := make([]domain.Todo, 0)
+	for i, write := range *writes {
+		if todo, ok := r.findTodo(ctx, write.Id); ok && write.Id != "" {
+			saved[i] = todo
+			continue
+		}
+
+		owner := ownerId
+		if all && write.OwnerId != "" {
+			owner = write.OwnerId
+		}
+		todo, err := r.createTodo(owner, &domain.NewTodo{
+			Description: write.Description,
+			Done:        write.Done,
+		})
+		if err != nil {
+			for _, c := range created {
+				r.removeTodo(&c)
+			}
+			return nil, err
+		}
+		saved[i] = todo
+		created = append(created, *todo)
+	}
+
+	todos := make([]domain.Todo, 0, len(saved))
+	for i, todo := range saved {
+		if write := (*writes)[i]; write.Id != "" && todo.Id == write.Id {
+			r.updateTodo(ctx, todo, &domain.UpdateTodo{
+				Description: write.Description,
+				Done:        write.Done,
+			})
+		}
+		todos = append(todos, *todo)
+	}
+
+	r.logger(ctx).Debug("Stored todos", "count", len(todos), "created", len(created), "seq", r.seq)
+	return &todos, nil
+}
+
+// createTodo must be called with the write lock held
+func (r *InMemoryTodoRepository) createTodo(ownerId string, newTodo *domain.NewTodo) (*domain.Todo, error) {
+	if err := domain.ValidateDescription(newTodo.Description); err != nil {
+		return nil, err
+	}
+
 	// random uuidV4
 	id := uuid.New().String()
 
@@ -38,57 +176,331 @@ func (r *InMemoryTodoRepository) CreateTodo(newTodo *domain.NewTodo) (*domain.To
 		return nil, ErrTodoAlreadyExists
 	}
 
+	now := time.Now()
 	todo := &domain.Todo{
 		Id:          id,
+		OwnerId:     ownerId,
+		Done:        newTodo.Done,
 		Description: newTodo.Description,
-		CreatedAt:   time.Now(),
+		CreatedAt:   now,
+		Seq:         r.nextSeq(),
+	}
+	if newTodo.Done {
+		todo.DoneAt = now
 	}
 
 	r.todos[id] = todo
+	if r.owners[ownerId] == nil {
+		r.owners[ownerId] = make(map[string]struct{})
+	}
+	r.owners[ownerId][id] = struct{}{}
 
 	return todo, nil
 }
 
-func (r *InMemoryTodoRepository) GetTodo(id string) (*domain.Todo, error) {
+// removeTodo must be called with the write lock held
+func (r *InMemoryTodoRepository) removeTodo(todo *domain.Todo) {
+	delete(r.todos, todo.Id)
+	delete(r.owners[todo.OwnerId], todo.Id)
+	if len(r.owners[todo.OwnerId]) == 0 {
+		delete(r.owners, todo.OwnerId)
+	}
+}
+
+// findTodo returns the todo with id if the principal of ctx may reach it. It
+// must be called with the lock held
+func (r *InMemoryTodoRepository) findTodo(ctx context.Context, id string) (*domain.Todo, bool) {
 	todo, ok := r.todos[id]
+	if !ok {
+		return nil, false
+	}
+
+	ownerId, all := domain.OwnerFromContext(ctx)
+	return todo, all || todo.OwnerId == ownerId
+}
+
+func (r *InMemoryTodoRepository) GetTodo(ctx context.Context, id string) (*domain.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.findTodo(ctx, id)
 	if !ok {
 		return nil, ErrTodoDoesNotExist
 	}
 
-	return todo, nil
+	return copyTodo(todo), nil
 }
 
-func (r *InMemoryTodoRepository) GetTodos() (*[]domain.Todo, error) {
+func (r *InMemoryTodoRepository) GetTodos(ctx context.Context) (*[]domain.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	todos := make([]domain.Todo, 0)
 
-	for _, v := range r.todos {
-		todos = append(todos, *v)
+	ownerId, all := domain.OwnerFromContext(ctx)
+	if all {
+		for _, v := range r.todos {
+			todos = append(todos, *v)
+		}
+		return &todos, nil
+	}
+
+	for id := range r.owners[ownerId] {
+		todos = append(todos, *r.todos[id])
 	}
 
 	return &todos, nil
 }
 
-func (r *InMemoryTodoRepository) UpdateTodo(id string, todo *domain.UpdateTodo) (*domain.Todo, error) {
+func (r *InMemoryTodoRepository) CountTodos(ctx context.Context) (*domain.TodoCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := &domain.TodoCount{}
+	add := func(todo *domain.Todo) {
+		count.Total++
+		if todo.Done {
+			count.Done++
+		}
+	}
+
+	ownerId, all := domain.OwnerFromContext(ctx)
+	if all {
+		for _, v := range r.todos {
+			add(v)
+		}
+		return count, nil
+	}
+
+	for id := range r.owners[ownerId] {
+		add(r.todos[id])
+	}
+
+	return count, nil
+}
+
+func (r *InMemoryTodoRepository) GetTodosPage(ctx context.Context, after *domain.TodoCursor, limit int) (*[]domain.Todo, error) {
+	if limit <= 0 {
+		return nil, ErrInvalidParameter
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// keep the first limit todos after the cursor in order, so a page never
+	// holds more than limit todos whatever the size of the store
+	page := make([]domain.Todo, 0, limit)
+	add := func(todo *domain.Todo) {
+		if after != nil && !after.Before(todo) {
+			return
+		}
+
+		cursor := domain.CursorOf(todo)
+		i := sort.Search(len(page), func(i int) bool {
+			return cursor.Before(&page[i])
+		})
+		if i == limit {
+			return
+		}
+		if len(page) == limit {
+			page = page[:limit-1]
+		}
+		page = slices.Insert(page, i, *todo)
+	}
+
+	ownerId, all := domain.OwnerFromContext(ctx)
+	if all {
+		for _, v := range r.todos {
+			add(v)
+		}
+		return &page, nil
+	}
+
+	for id := range r.owners[ownerId] {
+		add(r.todos[id])
+	}
+
+	return &page, nil
+}
+
+func (r *InMemoryTodoRepository) UpdateTodo(ctx context.Context, id string, todo *domain.UpdateTodo) (*domain.Todo, error) {
+	if todo == nil {
+		return nil, ErrInvalidParameter
+	}
+	if err := domain.ValidateDescription(todo.Description); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.findTodo(ctx, id)
+	if !ok {
+		return nil, ErrTodoDoesNotExist
+	}
+
+	r.updateTodo(ctx, stored, todo)
+	return copyTodo(stored), nil
+}
+
+func (r *InMemoryTodoRepository) UpdateTodoIfUnchanged(ctx context.Context, id string, seq uint64, todo *domain.UpdateTodo) (*domain.Todo, error) {
 	if todo == nil {
 		return nil, ErrInvalidParameter
 	}
+	if err := domain.ValidateDescription(todo.Description); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	if _, ok := r.todos[id]; !ok {
+	stored, ok := r.findTodo(ctx, id)
+	if !ok {
 		return nil, ErrTodoDoesNotExist
 	}
+	if stored.Seq != seq {
+		return nil, domain.ErrTodoChanged
+	}
 
-	r.todos[id].Done = todo.Done
+	r.updateTodo(ctx, stored, todo)
+	return copyTodo(stored), nil
+}
+
+// updateTodo must be called with the write lock held
+func (r *InMemoryTodoRepository) updateTodo(ctx context.Context, stored *domain.Todo, todo *domain.UpdateTodo) {
+	stored.Done = todo.Done
 	if todo.Done {
-		r.todos[id].DoneAt = time.Now()
+		stored.DoneAt = time.Now()
+	}
+	stored.Description = todo.Description
+	stored.UpdatedAt = time.Now()
+	stored.Seq = r.nextSeq()
+
+	r.logger(ctx).Debug("Stored todo update", "todoId", stored.Id, "seq", r.seq)
+}
+
+func (r *InMemoryTodoRepository) DeleteTodo(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.findTodo(ctx, id)
+	if !ok {
+		return ErrTodoDoesNotExist
 	}
-	r.todos[id].Description = todo.Description
-	r.todos[id].UpdatedAt = time.Now()
 
-	return r.todos[id], nil
+	r.deleteTodo(ctx, todo)
+	return nil
 }
 
-func (r *InMemoryTodoRepository) DeleteTodo(id string) error {
-	delete(r.todos, id)
+func (r *InMemoryTodoRepository) DeleteTodoIfUnchanged(ctx context.Context, id string, seq uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.findTodo(ctx, id)
+	if !ok {
+		return ErrTodoDoesNotExist
+	}
+	if todo.Seq != seq {
+		return domain.ErrTodoChanged
+	}
 
+	r.deleteTodo(ctx, todo)
 	return nil
 }
+
+// deleteTodo must be called with the write lock held
+func (r *InMemoryTodoRepository) deleteTodo(ctx context.Context, todo *domain.Todo) {
+	r.removeTodo(todo)
+	r.pruneTombstones()
+	r.tombstones = append(r.tombstones, tombstone{
+		id:        todo.Id,
+		seq:       r.nextSeq(),
+		ownerId:   todo.OwnerId,
+		deletedAt: time.Now(),
+	})
+	r.logger(ctx).Debug("Removed todo", "todoId", todo.Id, "seq", r.seq)
+}
+
+// pruneTombstones drops the tombstones older than the retention, moving the
+// horizon up to the latest of them. It must be called with the write lock held
+func (r *InMemoryTodoRepository) pruneTombstones() {
+	cutoff := time.Now().Add(-r.retention)
+	n := sort.Search(len(r.tombstones), func(i int) bool {
+		return r.tombstones[i].deletedAt.After(cutoff)
+	})
+	if n == 0 {
+		return
+	}
+
+	r.horizon = r.tombstones[n-1].seq
+	r.tombstones = r.tombstones[n:]
+}
+
+func (r *InMemoryTodoRepository) GetChanges(ctx context.Context, since domain.SyncPosition) (*[]domain.TodoChange, domain.SyncPosition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if since != (domain.SyncPosition{}) && (since.Epoch != r.epoch || since.Seq > r.seq || since.Seq < r.horizon) {
+		return nil, domain.SyncPosition{}, domain.ErrSyncExpired
+	}
+
+	changes := make([]domain.TodoChange, 0)
+	ownerId, all := domain.OwnerFromContext(ctx)
+
+	for _, v := range r.todos {
+		if v.Seq > since.Seq && (all || v.OwnerId == ownerId) {
+			changes = append(changes, domain.TodoChange{
+				Seq:  v.Seq,
+				Todo: *v,
+			})
+		}
+	}
+
+	first := sort.Search(len(r.tombstones), func(i int) bool {
+		return r.tombstones[i].seq > since.Seq
+	})
+	for _, t := range r.tombstones[first:] {
+		if all || t.ownerId == ownerId {
+			changes = append(changes, domain.TodoChange{
+				Seq:     t.seq,
+				Deleted: true,
+				Todo: domain.Todo{
+					Id:      t.id,
+					OwnerId: t.ownerId,
+					Seq:     t.seq,
+				},
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Seq < changes[j].Seq
+	})
+
+	return &changes, domain.SyncPosition{Epoch: r.epoch, Seq: r.seq}, nil
+}
+
+// HealthCheck waits for the store lock, so a store that stays locked, which
+// would leave every request hanging, fails the check when it times out
+func (r *InMemoryTodoRepository) HealthCheck(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return ctx.Err()
+}
+
+// logger returns the logger of the request ctx belongs to, so entries carry its request id
+func (r *InMemoryTodoRepository) logger(ctx context.Context) domain.Logger {
+	return domain.LoggerFromContext(ctx, r.log).With("backend", "memory")
+}
+
+// nextSeq must be called with the write lock held
+func (r *InMemoryTodoRepository) nextSeq() uint64 {
+	r.seq++
+	return r.seq
+}
+
+func copyTodo(todo *domain.Todo) *domain.Todo {
+	c := *todo
+	return &c
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/slogger"
+)
+
+func TestTombstoneRetention(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.retention = time.Hour
+	ctx := context.Background()
+
+	first := createTestTodo(t, repo, ctx, "first")
+	second := createTestTodo(t, repo, ctx, "second")
+	_, before, err := repo.GetChanges(ctx, domain.SyncPosition{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteTodo(ctx, first.Id); err != nil {
+		t.Fatal(err)
+	}
+	_, between, err := repo.GetChanges(ctx, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// age the first deletion past the retention, so the next one prunes it
+	repo.tombstones[0].deletedAt = time.Now().Add(-2 * time.Hour)
+	if err := repo.DeleteTodo(ctx, second.Id); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.tombstones) != 1 {
+		t.Fatalf("expected only the second deletion to be kept, got %d tombstones", len(repo.tombstones))
+	}
+
+	if _, _, err := repo.GetChanges(ctx, before); !errors.Is(err, domain.ErrSyncExpired) {
+		t.Errorf("expected %v syncing from before the pruned deletion, got %v", domain.ErrSyncExpired, err)
+	}
+
+	changes, _, err := repo.GetChanges(ctx, between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*changes) != 1 || !(*changes)[0].Deleted || (*changes)[0].Todo.Id != second.Id {
+		t.Errorf("expected the deletion of the second todo, got %+v", *changes)
+	}
+
+	// fetching every todo does not need the pruned deletions
+	if _, _, err := repo.GetChanges(ctx, domain.SyncPosition{}); err != nil {
+		t.Errorf("unexpected error fetching every todo: %s", err)
+	}
+}
+
+func TestOwnerScoping(t *testing.T) {
+	repo := newTestRepository(t)
+	alice := domain.ContextWithPrincipal(context.Background(), &domain.Principal{Id: "alice", Scopes: []domain.Scope{domain.ScopeWrite}})
+	bob := domain.ContextWithPrincipal(context.Background(), &domain.Principal{Id: "bob", Scopes: []domain.Scope{domain.ScopeWrite}})
+	admin := domain.ContextWithPrincipal(context.Background(), &domain.Principal{Id: "admin", Scopes: []domain.Scope{domain.ScopeAdmin}})
+
+	todo := createTestTodo(t, repo, alice, "alice's todo")
+	createTestTodo(t, repo, bob, "bob's todo")
+	if todo.OwnerId != "alice" {
+		t.Fatalf("expected the todo to be owned by its creator, got %q", todo.OwnerId)
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		found   bool
+		visible int
+	}{
+		{name: "owner", ctx: alice, found: true, visible: 1},
+		{name: "other owner", ctx: bob, found: false, visible: 1},
+		{name: "admin", ctx: admin, found: true, visible: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expected error
+			if !tt.found {
+				expected = domain.ErrTodoNotFound
+			}
+
+			if _, err := repo.GetTodo(tt.ctx, todo.Id); !errors.Is(err, expected) {
+				t.Errorf("expected %v getting the todo, got %v", expected, err)
+			}
+			if _, err := repo.UpdateTodo(tt.ctx, todo.Id, &domain.UpdateTodo{Description: tt.name}); !errors.Is(err, expected) {
+				t.Errorf("expected %v updating the todo, got %v", expected, err)
+			}
+
+			todos, err := repo.GetTodos(tt.ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*todos) != tt.visible {
+				t.Errorf("expected %d todos to be listed, got %d", tt.visible, len(*todos))
+			}
+		})
+	}
+
+	if err := repo.DeleteTodo(bob, todo.Id); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected %v deleting another owner's todo, got %v", domain.ErrTodoNotFound, err)
+	}
+	stored, err := repo.GetTodo(alice, todo.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Description != "admin" || stored.OwnerId != "alice" {
+		t.Errorf("expected only the owner and admin updates to apply, got %+v", stored)
+	}
+}
+
+func newTestRepository(t *testing.T) *InMemoryTodoRepository {
+	t.Helper()
+
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(log)
+}
+
+func createTestTodo(t *testing.T, repo *InMemoryTodoRepository, ctx context.Context, description string) *domain.Todo {
+	t.Helper()
+
+	todo, err := repo.CreateTodo(ctx, &domain.NewTodo{Description: description})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return todo
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const NAMESPACE = "todo"
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by operation and status code.",
+		}, []string{"operation", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by operation and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: NAMESPACE,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being handled.",
+		}),
+		timeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "http_request_timeouts_total",
+			Help:      "HTTP requests that exceeded their timeout, by operation.",
+		}, []string{"operation"}),
+		repoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Name:      "repository_operation_duration_seconds",
+			Help:      "Time taken by todo repository operations, by operation and result.",
+			Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+		}, []string{"operation", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.timeouts,
+		m.repoDuration,
+	)
+
+	return m
+}
+
+// Metrics holds the service's Prometheus collectors in a registry of its own,
+// so separate instances do not clash over the global registry
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	timeouts        *prometheus.CounterVec
+	repoDuration    *prometheus.HistogramVec
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records the count, latency and status of every request. The
+// operation is read once the request has been routed, so operation can rely
+// on the matched route
+func (m *Metrics) Middleware(operation func(r *http.Request) string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			m.inFlight.Inc()
+			defer m.inFlight.Dec()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				labels := prometheus.Labels{
+					"operation": operation(r),
+					"status":    strconv.Itoa(status),
+				}
+				m.requests.With(labels).Inc()
+				m.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequestTimedOut counts a request that was cut off by its timeout
+func (m *Metrics) RequestTimedOut(operation string) {
+	m.timeouts.WithLabelValues(operation).Inc()
+}
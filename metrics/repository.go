@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	RESULT_OK    = "ok"
+	RESULT_ERROR = "error"
+)
+
+// Repository wraps repo so the latency of each of its operations is recorded,
+// and reports the number of todos stored in every namespace returned by
+// namespaces whenever the metrics are scraped
+func (m *Metrics) Repository(repo domain.TodoRepository, namespaces func() []string) *InstrumentedTodoRepository {
+	m.registry.MustRegister(&todoCollector{repo: repo, namespaces: namespaces})
+
+	return &InstrumentedTodoRepository{
+		repo:    repo,
+		metrics: m,
+	}
+}
+
+type InstrumentedTodoRepository struct {
+	repo    domain.TodoRepository
+	metrics *Metrics
+}
+
+func (r *InstrumentedTodoRepository) observe(operation string, start time.Time, err error) {
+	result := RESULT_OK
+	if err != nil {
+		result = RESULT_ERROR
+	}
+
+	r.metrics.repoDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+func (r *InstrumentedTodoRepository) CreateTodo(ctx context.Context, newTodo *domain.NewTodo) (*domain.Todo, error) {
+	start := time.Now()
+	todo, err := r.repo.CreateTodo(ctx, newTodo)
+	r.observe("CreateTodo", start, err)
+	return todo, err
+}
+
+func (r *InstrumentedTodoRepository) CreateTodos(ctx context.Context, newTodos *[]domain.NewTodo) (*[]domain.Todo, error) {
+	start := time.Now()
+	todos, err := r.repo.CreateTodos(ctx, newTodos)
+	r.observe("CreateTodos", start, err)
+	return todos, err
+}
+
+func (r *InstrumentedTodoRepository) SaveTodos(ctx context.Context, writes *[]domain.TodoWrite) (*[]domain.Todo, error) {
+	start := time.Now()
+	todos, err := r.repo.SaveTodos(ctx, writes)
+	r.observe("SaveTodos", start, err)
+	return todos, err
+}
+
+func (r *InstrumentedTodoRepository) GetTodo(ctx context.Context, id string) (*domain.Todo, error) {
+	start := time.Now()
+	todo, err := r.repo.GetTodo(ctx, id)
+	r.observe("GetTodo", start, err)
+	return todo, err
+}
+
+func (r *InstrumentedTodoRepository) GetTodos(ctx context.Context) (*[]domain.Todo, error) {
+	start := time.Now()
+	todos, err := r.repo.GetTodos(ctx)
+	r.observe("GetTodos", start, err)
+	return todos, err
+}
+
+func (r *InstrumentedTodoRepository) CountTodos(ctx context.Context) (*domain.TodoCount, error) {
+	start := time.Now()
+	count, err := r.repo.CountTodos(ctx)
+	r.observe("CountTodos", start, err)
+	return count, err
+}
+
+func (r *InstrumentedTodoRepository) GetTodosPage(ctx context.Context, after *domain.TodoCursor, limit int) (*[]domain.Todo, error) {
+	start := time.Now()
+	todos, err := r.repo.GetTodosPage(ctx, after, limit)
+	r.observe("GetTodosPage", start, err)
+	return todos, err
+}
+
+func (r *InstrumentedTodoRepository) UpdateTodo(ctx context.Context, id string, update *domain.UpdateTodo) (*domain.Todo, error) {
+	start := time.Now()
+	todo, err := r.repo.UpdateTodo(ctx, id, update)
+	r.observe("UpdateTodo", start, err)
+	return todo, err
+}
+
+func (r *InstrumentedTodoRepository) DeleteTodo(ctx context.Context, id string) error {
+	start := time.Now()
+	err := r.repo.DeleteTodo(ctx, id)
+	r.observe("DeleteTodo", start, err)
+	return err
+}
+
+func (r *InstrumentedTodoRepository) UpdateTodoIfUnchanged(ctx context.Context, id string, seq uint64, update *domain.UpdateTodo) (*domain.Todo, error) {
+	start := time.Now()
+	todo, err := r.repo.UpdateTodoIfUnchanged(ctx, id, seq, update)
+	r.observe("UpdateTodoIfUnchanged", start, err)
+	return todo, err
+}
+
+func (r *InstrumentedTodoRepository) DeleteTodoIfUnchanged(ctx context.Context, id string, seq uint64) error {
+	start := time.Now()
+	err := r.repo.DeleteTodoIfUnchanged(ctx, id, seq)
+	r.observe("DeleteTodoIfUnchanged", start, err)
+	return err
+}
+
+func (r *InstrumentedTodoRepository) GetChanges(ctx context.Context, since domain.SyncPosition) (*[]domain.TodoChange, domain.SyncPosition, error) {
+	start := time.Now()
+	changes, latest, err := r.repo.GetChanges(ctx, since)
+	r.observe("GetChanges", start, err)
+	return changes, latest, err
+}
+
+var todosDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(NAMESPACE, "", "todos"),
+	"Todos currently stored, by state.",
+	[]string{"state"}, nil,
+)
+
+// todoCollector counts the stored todos at scrape time, so the gauges cannot
+// drift from the repository
+type todoCollector struct {
+	repo       domain.TodoRepository
+	namespaces func() []string
+}
+
+func (c *todoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- todosDesc
+}
+
+func (c *todoCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := domain.ContextWithPrincipal(context.Background(), domain.SystemPrincipal)
+
+	total := &domain.TodoCount{}
+	for _, namespace := range c.namespaces() {
+		count, err := c.repo.CountTodos(domain.ContextWithNamespace(ctx, namespace))
+		if errors.Is(err, tenant.ErrNamespaceNotFound) {
+			// deleted since it was listed
+			continue
+		}
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(todosDesc, err)
+			return
+		}
+
+		total.Total += count.Total
+		total.Done += count.Done
+	}
+
+	ch <- prometheus.MustNewConstMetric(todosDesc, prometheus.GaugeValue, float64(total.Total), "total")
+	ch <- prometheus.MustNewConstMetric(todosDesc, prometheus.GaugeValue, float64(total.Done), "done")
+}
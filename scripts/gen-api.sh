@@ -40,6 +40,7 @@ mkdir -p $folderPath
 # codegen
 oapi-codegen -generate types -o $folderPath/$package-types.gen.go -package $package $specPath
 oapi-codegen -generate "chi-server" -o $folderPath/$package.gen.go -package $package $specPath
+oapi-codegen -generate client -response-type-suffix HTTPResponse -o $folderPath/$package-client.gen.go -package $package $specPath
 
 # go
 go mod tidy
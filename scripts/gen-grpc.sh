@@ -0,0 +1,47 @@
+#!/bin/bash
+
+# go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.34.2
+# go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.5.1
+
+# setup
+folderPath="grpc/generated"
+protoDir="grpc"
+protoFile="todo.proto"
+
+# validate scripts are installed
+if ! command -v protoc &> /dev/null
+then
+	echo "Please install \`protoc\` before running this script"
+	exit 1
+fi
+
+if ! command -v protoc-gen-go &> /dev/null
+then
+	echo "Please install \`protoc-gen-go\` before running this script"
+	echo "Hint: run \`go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.34.2\` to install"
+	exit 1
+fi
+
+if ! command -v protoc-gen-go-grpc &> /dev/null
+then
+	echo "Please install \`protoc-gen-go-grpc\` before running this script"
+	echo "Hint: run \`go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.5.1\` to install"
+	exit 1
+fi
+
+# navigate to repo root
+rootDir=$(git rev-parse --show-toplevel)
+cd $rootDir
+
+# cleanup
+rm -r $folderPath
+mkdir -p $folderPath
+
+# codegen
+protoc -I $protoDir \
+	--go_out=$folderPath --go_opt=paths=source_relative \
+	--go-grpc_out=$folderPath --go-grpc_opt=paths=source_relative \
+	$protoFile
+
+# go
+go mod tidy
@@ -0,0 +1,135 @@
+package sharing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+// Todos are always created in the caller's own list, and GetTodos,
+// CountTodos, GetTodosPage and GetChanges only cover it, so those calls go
+// straight to the repository
+
+func (p *Policy) CreateTodo(ctx context.Context, newTodo *domain.NewTodo) (*domain.Todo, error) {
+	return p.repo.CreateTodo(ctx, newTodo)
+}
+
+func (p *Policy) CreateTodos(ctx context.Context, newTodos *[]domain.NewTodo) (*[]domain.Todo, error) {
+	return p.repo.CreateTodos(ctx, newTodos)
+}
+
+// SaveTodos needs the editor role on the list of every todo it updates. Writes
+// to todos the caller cannot see create todos in the caller's own list instead
+func (p *Policy) SaveTodos(ctx context.Context, writes *[]domain.TodoWrite) (*[]domain.Todo, error) {
+	principal := domain.PrincipalFromContext(ctx)
+	if principal == nil || writes == nil {
+		return p.repo.SaveTodos(ctx, writes)
+	}
+
+	authorized := make([]domain.TodoWrite, 0, len(*writes))
+	for _, write := range *writes {
+		if write.Id != "" {
+			_, err := p.authorize(ctx, write.Id, domain.RoleEditor)
+			if errors.Is(err, domain.ErrTodoNotFound) {
+				write.Id = ""
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		write.OwnerId = principal.Id
+		authorized = append(authorized, write)
+	}
+
+	return p.repo.SaveTodos(elevate(ctx), &authorized)
+}
+
+func (p *Policy) GetTodos(ctx context.Context) (*[]domain.Todo, error) {
+	return p.repo.GetTodos(ctx)
+}
+
+func (p *Policy) CountTodos(ctx context.Context) (*domain.TodoCount, error) {
+	return p.repo.CountTodos(ctx)
+}
+
+func (p *Policy) GetTodosPage(ctx context.Context, after *domain.TodoCursor, limit int) (*[]domain.Todo, error) {
+	return p.repo.GetTodosPage(ctx, after, limit)
+}
+
+func (p *Policy) GetChanges(ctx context.Context, since domain.SyncPosition) (*[]domain.TodoChange, domain.SyncPosition, error) {
+	return p.repo.GetChanges(ctx, since)
+}
+
+func (p *Policy) GetTodo(ctx context.Context, id string) (*domain.Todo, error) {
+	return p.authorize(ctx, id, domain.RoleViewer)
+}
+
+func (p *Policy) UpdateTodo(ctx context.Context, id string, todo *domain.UpdateTodo) (*domain.Todo, error) {
+	if _, err := p.authorize(ctx, id, domain.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	return p.repo.UpdateTodo(elevate(ctx), id, todo)
+}
+
+func (p *Policy) DeleteTodo(ctx context.Context, id string) error {
+	if _, err := p.authorize(ctx, id, domain.RoleEditor); err != nil {
+		return ignoreNotFound(err)
+	}
+
+	return ignoreNotFound(p.repo.DeleteTodo(elevate(ctx), id))
+}
+
+func (p *Policy) UpdateTodoIfUnchanged(ctx context.Context, id string, seq uint64, todo *domain.UpdateTodo) (*domain.Todo, error) {
+	if _, err := p.authorize(ctx, id, domain.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	return p.repo.UpdateTodoIfUnchanged(elevate(ctx), id, seq, todo)
+}
+
+// DeleteTodoIfUnchanged reports todos that cannot be found, unlike DeleteTodo,
+// so callers can tell them apart from todos that changed
+func (p *Policy) DeleteTodoIfUnchanged(ctx context.Context, id string, seq uint64) error {
+	if _, err := p.authorize(ctx, id, domain.RoleEditor); err != nil {
+		return err
+	}
+
+	return p.repo.DeleteTodoIfUnchanged(elevate(ctx), id, seq)
+}
+
+// ignoreNotFound lets deleting a todo that cannot be found succeed, as before,
+// including one removed by a concurrent call after it was authorized
+func ignoreNotFound(err error) error {
+	if errors.Is(err, domain.ErrTodoNotFound) {
+		return nil
+	}
+	return err
+}
+
+// authorize returns the todo with id if the caller's role on its list allows
+// required. Todos on lists the caller cannot see are reported missing
+func (p *Policy) authorize(ctx context.Context, id string, required domain.Role) (*domain.Todo, error) {
+	// without authentication every todo is shared, leave it to the repository
+	if domain.PrincipalFromContext(ctx) == nil {
+		return p.repo.GetTodo(ctx, id)
+	}
+
+	todo, err := p.repo.GetTodo(elevate(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	switch err := p.require(ctx, todo.OwnerId, required); {
+	case errors.Is(err, ErrListNotFound):
+		return nil, domain.ErrTodoNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	return todo, nil
+}
@@ -0,0 +1,314 @@
+package sharing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnauthenticated     = fmt.Errorf("sharing requires an authenticated caller")
+	ErrInvalidRole         = fmt.Errorf("role must be one of viewer, editor or owner")
+	ErrEmptyUserId         = fmt.Errorf("userId must not be empty")
+	ErrInviteSelf          = fmt.Errorf("the list owner cannot be invited to their own list")
+	ErrListNotFound        = fmt.Errorf("list not found")
+	ErrInvitationNotFound  = fmt.Errorf("invitation not found")
+	ErrCollaboratorMissing = fmt.Errorf("collaborator not found")
+	ErrInsufficientRole    = fmt.Errorf("role on the list does not allow this")
+)
+
+// New wraps repo with the policy deciding who may reach each todo: its owner,
+// the collaborators on its owner's list, and principals with the admin scope
+func New(repo domain.TodoRepository) *Policy {
+	return &Policy{
+		repo:          repo,
+		invitations:   make(map[string]*invitation),
+		collaborators: make(map[listKey]map[string]*domain.Collaborator),
+	}
+}
+
+type Policy struct {
+	repo domain.TodoRepository
+	mu   sync.RWMutex
+	// invitations holds the pending invitations by id
+	invitations   map[string]*invitation
+	collaborators map[listKey]map[string]*domain.Collaborator
+}
+
+// lists are shared within a namespace, the same owner id in another
+// namespace is another list
+type listKey struct {
+	namespace string
+	listId    string
+}
+
+type invitation struct {
+	domain.Invitation
+	namespace string
+}
+
+// Invite offers role on the list to userId. Only the list owner and
+// collaborators with the owner role may invite
+func (p *Policy) Invite(ctx context.Context, listId string, userId string, role domain.Role) (*domain.Invitation, error) {
+	principal, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if userId == "" {
+		return nil, ErrEmptyUserId
+	}
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	if userId == listId {
+		return nil, ErrInviteSelf
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.require(ctx, listId, domain.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	inv := &invitation{
+		Invitation: domain.Invitation{
+			Id:        uuid.NewString(),
+			ListId:    listId,
+			UserId:    userId,
+			Role:      role,
+			InvitedBy: principal.Id,
+			CreatedAt: time.Now(),
+		},
+		namespace: domain.NamespaceFromContext(ctx),
+	}
+	p.invitations[inv.Id] = inv
+
+	return &inv.Invitation, nil
+}
+
+// Invitations returns the pending invitations addressed to the caller, oldest first
+func (p *Policy) Invitations(ctx context.Context) ([]domain.Invitation, error) {
+	principal, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	namespace := domain.NamespaceFromContext(ctx)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	invitations := make([]domain.Invitation, 0)
+	for _, inv := range p.invitations {
+		if inv.namespace == namespace && inv.UserId == principal.Id {
+			invitations = append(invitations, inv.Invitation)
+		}
+	}
+
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].CreatedAt.Before(invitations[j].CreatedAt)
+	})
+
+	return invitations, nil
+}
+
+// Accept makes the caller a collaborator on the list they were invited to,
+// replacing any role they already had on it
+func (p *Policy) Accept(ctx context.Context, id string) (*domain.Collaborator, error) {
+	principal, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// invitations addressed to someone else are reported missing
+	inv, ok := p.invitations[id]
+	if !ok || inv.namespace != domain.NamespaceFromContext(ctx) || inv.UserId != principal.Id {
+		return nil, ErrInvitationNotFound
+	}
+	delete(p.invitations, id)
+
+	key := listKey{namespace: inv.namespace, listId: inv.ListId}
+	if p.collaborators[key] == nil {
+		p.collaborators[key] = make(map[string]*domain.Collaborator)
+	}
+	collaborator := &domain.Collaborator{
+		ListId:  inv.ListId,
+		UserId:  inv.UserId,
+		Role:    inv.Role,
+		AddedAt: time.Now(),
+	}
+	p.collaborators[key][inv.UserId] = collaborator
+
+	c := *collaborator
+	return &c, nil
+}
+
+// Collaborators returns everyone the list is shared with, ordered by user id
+func (p *Policy) Collaborators(ctx context.Context, listId string) ([]domain.Collaborator, error) {
+	if _, err := caller(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if err := p.require(ctx, listId, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	collaborators := make([]domain.Collaborator, 0)
+	for _, c := range p.collaborators[key(ctx, listId)] {
+		collaborators = append(collaborators, *c)
+	}
+
+	sort.Slice(collaborators, func(i, j int) bool {
+		return collaborators[i].UserId < collaborators[j].UserId
+	})
+
+	return collaborators, nil
+}
+
+// RemoveCollaborator stops sharing the list with userId. Collaborators may
+// remove themselves, removing anyone else needs the owner role
+func (p *Policy) RemoveCollaborator(ctx context.Context, listId string, userId string) error {
+	principal, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if userId != principal.Id {
+		if err := p.require(ctx, listId, domain.RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	k := key(ctx, listId)
+	if _, ok := p.collaborators[k][userId]; !ok {
+		return ErrCollaboratorMissing
+	}
+	delete(p.collaborators[k], userId)
+	if len(p.collaborators[k]) == 0 {
+		delete(p.collaborators, k)
+	}
+
+	return nil
+}
+
+// SharedWithMe returns every list shared with the caller along with its
+// todos, ordered by list id
+func (p *Policy) SharedWithMe(ctx context.Context) ([]domain.SharedList, error) {
+	principal, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	namespace := domain.NamespaceFromContext(ctx)
+
+	p.mu.RLock()
+	lists := make([]domain.SharedList, 0)
+	for k, collaborators := range p.collaborators {
+		if c, ok := collaborators[principal.Id]; ok && k.namespace == namespace {
+			lists = append(lists, domain.SharedList{ListId: k.listId, Role: c.Role})
+		}
+	}
+	p.mu.RUnlock()
+
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].ListId < lists[j].ListId
+	})
+
+	for i := range lists {
+		todos, err := p.listTodos(ctx, lists[i].ListId)
+		if err != nil {
+			return nil, err
+		}
+		lists[i].Todos = todos
+	}
+
+	return lists, nil
+}
+
+// ClearNamespace forgets every invitation and collaborator of the namespace,
+// for when its todos are dropped
+func (p *Policy) ClearNamespace(namespace string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, inv := range p.invitations {
+		if inv.namespace == namespace {
+			delete(p.invitations, id)
+		}
+	}
+	for k := range p.collaborators {
+		if k.namespace == namespace {
+			delete(p.collaborators, k)
+		}
+	}
+}
+
+// listTodos returns the todos owned by listId, reading them as the owner so
+// only their todos are looked up
+func (p *Policy) listTodos(ctx context.Context, listId string) ([]domain.Todo, error) {
+	todos, err := p.repo.GetTodos(domain.ContextWithPrincipal(ctx, &domain.Principal{Id: listId}))
+	if err != nil {
+		return nil, err
+	}
+
+	return *todos, nil
+}
+
+// role returns the caller's role on the list, or an empty role if they have
+// none. It must be called with the lock held
+func (p *Policy) role(ctx context.Context, listId string) domain.Role {
+	ownerId, all := domain.OwnerFromContext(ctx)
+	if all || ownerId == listId {
+		return domain.RoleOwner
+	}
+
+	if c, ok := p.collaborators[key(ctx, listId)][ownerId]; ok {
+		return c.Role
+	}
+	return ""
+}
+
+// require fails unless the caller's role on the list allows required. Lists
+// the caller cannot see at all are reported missing. It must be called with
+// the lock held
+func (p *Policy) require(ctx context.Context, listId string, required domain.Role) error {
+	role := p.role(ctx, listId)
+	if role == "" {
+		return ErrListNotFound
+	}
+	if !role.Allows(required) {
+		return ErrInsufficientRole
+	}
+	return nil
+}
+
+func caller(ctx context.Context) (*domain.Principal, error) {
+	principal := domain.PrincipalFromContext(ctx)
+	if principal == nil {
+		return nil, ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+func key(ctx context.Context, listId string) listKey {
+	return listKey{namespace: domain.NamespaceFromContext(ctx), listId: listId}
+}
+
+// elevate lets the repository return the todos of every owner, once the
+// policy has checked the caller may reach them
+func elevate(ctx context.Context) context.Context {
+	return domain.ContextWithPrincipal(ctx, domain.SystemPrincipal)
+}
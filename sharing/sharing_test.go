@@ -0,0 +1,183 @@
+package sharing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/memory"
+	"github.com/brendenehlers/todo-microservice/slogger"
+)
+
+func TestAuthorize(t *testing.T) {
+	policy := newTestPolicy(t)
+	owner := userContext("owner")
+	todo := createTestTodo(t, policy, owner, "shared todo")
+	share(t, policy, owner, "owner", "viewer", domain.RoleViewer)
+	share(t, policy, owner, "owner", "editor", domain.RoleEditor)
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		read  error
+		write error
+	}{
+		{name: "owner", ctx: owner},
+		{name: "editor", ctx: userContext("editor")},
+		{name: "viewer", ctx: userContext("viewer"), write: ErrInsufficientRole},
+		{name: "not a collaborator", ctx: userContext("stranger"), read: domain.ErrTodoNotFound, write: domain.ErrTodoNotFound},
+		{name: "admin", ctx: domain.ContextWithPrincipal(context.Background(), &domain.Principal{Id: "admin", Scopes: []domain.Scope{domain.ScopeAdmin}})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := policy.GetTodo(tt.ctx, todo.Id); !errors.Is(err, tt.read) {
+				t.Errorf("expected %v getting the todo, got %v", tt.read, err)
+			}
+			if _, err := policy.UpdateTodo(tt.ctx, todo.Id, &domain.UpdateTodo{Description: tt.name}); !errors.Is(err, tt.write) {
+				t.Errorf("expected %v updating the todo, got %v", tt.write, err)
+			}
+			// a stale sequence number gets past the policy without deleting the todo
+			expected := tt.write
+			if expected == nil {
+				expected = domain.ErrTodoChanged
+			}
+			if err := policy.DeleteTodoIfUnchanged(tt.ctx, todo.Id, 0); !errors.Is(err, expected) {
+				t.Errorf("expected %v deleting the todo, got %v", expected, err)
+			}
+		})
+	}
+
+	// deleting a todo the caller cannot see succeeds without removing it
+	if err := policy.DeleteTodo(userContext("stranger"), todo.Id); err != nil {
+		t.Errorf("unexpected error deleting a todo that cannot be found: %s", err)
+	}
+	if err := policy.DeleteTodo(userContext("viewer"), todo.Id); !errors.Is(err, ErrInsufficientRole) {
+		t.Errorf("expected %v deleting as a viewer, got %v", ErrInsufficientRole, err)
+	}
+	if _, err := policy.GetTodo(owner, todo.Id); err != nil {
+		t.Fatalf("expected the todo to survive callers that may not delete it, got %v", err)
+	}
+
+	if err := policy.DeleteTodo(userContext("editor"), todo.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.GetTodo(owner, todo.Id); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected the editor to delete the todo, got %v", err)
+	}
+}
+
+func TestInvitations(t *testing.T) {
+	policy := newTestPolicy(t)
+	owner := userContext("owner")
+	invitee := userContext("invitee")
+	createTestTodo(t, policy, owner, "shared todo")
+	share(t, policy, owner, "owner", "editor", domain.RoleEditor)
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		userId string
+		role   domain.Role
+		err    error
+	}{
+		{name: "unauthenticated", ctx: context.Background(), userId: "invitee", role: domain.RoleViewer, err: ErrUnauthenticated},
+		{name: "no user", ctx: owner, role: domain.RoleViewer, err: ErrEmptyUserId},
+		{name: "unknown role", ctx: owner, userId: "invitee", role: "admin", err: ErrInvalidRole},
+		{name: "the owner", ctx: owner, userId: "owner", role: domain.RoleViewer, err: ErrInviteSelf},
+		{name: "by an editor", ctx: userContext("editor"), userId: "invitee", role: domain.RoleViewer, err: ErrInsufficientRole},
+		{name: "by a stranger", ctx: userContext("stranger"), userId: "invitee", role: domain.RoleViewer, err: ErrListNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := policy.Invite(tt.ctx, "owner", tt.userId, tt.role); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	inv, err := policy.Invite(owner, "owner", "invitee", domain.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invitations, err := policy.Invitations(userContext("stranger")); err != nil || len(invitations) != 0 {
+		t.Errorf("expected invitations to be private to the invitee, got %+v, %v", invitations, err)
+	}
+	if _, err := policy.Accept(userContext("stranger"), inv.Id); !errors.Is(err, ErrInvitationNotFound) {
+		t.Errorf("expected %v accepting someone else's invitation, got %v", ErrInvitationNotFound, err)
+	}
+	if _, err := policy.Accept(invitee, inv.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.Accept(invitee, inv.Id); !errors.Is(err, ErrInvitationNotFound) {
+		t.Errorf("expected %v accepting an invitation twice, got %v", ErrInvitationNotFound, err)
+	}
+
+	collaborators, err := policy.Collaborators(invitee, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collaborators) != 2 || collaborators[0].UserId != "editor" || collaborators[1].UserId != "invitee" {
+		t.Errorf("expected the editor and invitee to be collaborators, got %+v", collaborators)
+	}
+
+	shared, err := policy.SharedWithMe(invitee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shared) != 1 || shared[0].ListId != "owner" || shared[0].Role != domain.RoleViewer || len(shared[0].Todos) != 1 {
+		t.Errorf("expected the owner's list to be shared with its todos, got %+v", shared)
+	}
+
+	if err := policy.RemoveCollaborator(userContext("editor"), "owner", "invitee"); !errors.Is(err, ErrInsufficientRole) {
+		t.Errorf("expected %v removing someone else as an editor, got %v", ErrInsufficientRole, err)
+	}
+	if err := policy.RemoveCollaborator(invitee, "owner", "invitee"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.Collaborators(invitee, "owner"); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("expected %v once the invitee left the list, got %v", ErrListNotFound, err)
+	}
+}
+
+func newTestPolicy(t *testing.T) *Policy {
+	t.Helper()
+
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(memory.New(log))
+}
+
+func userContext(id string) context.Context {
+	return domain.ContextWithPrincipal(context.Background(), &domain.Principal{
+		Id:     id,
+		Scopes: []domain.Scope{domain.ScopeWrite},
+	})
+}
+
+func createTestTodo(t *testing.T, policy *Policy, ctx context.Context, description string) *domain.Todo {
+	t.Helper()
+
+	todo, err := policy.CreateTodo(ctx, &domain.NewTodo{Description: description})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return todo
+}
+
+// share invites userId to the list and accepts the invitation for them
+func share(t *testing.T, policy *Policy, ctx context.Context, listId string, userId string, role domain.Role) {
+	t.Helper()
+
+	inv, err := policy.Invite(ctx, listId, userId, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.Accept(userContext(userId), inv.Id); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,17 +1,67 @@
 package slogger
 
-import "log/slog"
+import (
+	"fmt"
+	"log/slog"
+	"os"
 
-type Slogger struct{}
+	"github.com/brendenehlers/todo-microservice/domain"
+)
 
-func New() *Slogger {
-	return &Slogger{}
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+)
+
+var (
+	ErrInvalidFormat = fmt.Errorf("invalid log format")
+)
+
+type Slogger struct {
+	log *slog.Logger
+}
+
+func New(level string, format string) (*Slogger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch format {
+	case FORMAT_TEXT:
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case FORMAT_JSON:
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return nil, ErrInvalidFormat
+	}
+
+	return &Slogger{
+		log: slog.New(handler),
+	}, nil
+}
+
+func (s *Slogger) Debug(msg string, args ...any) {
+	s.log.Debug(msg, args...)
+}
+
+func (s *Slogger) Info(msg string, args ...any) {
+	s.log.Info(msg, args...)
+}
+
+func (s *Slogger) Warn(msg string, args ...any) {
+	s.log.Warn(msg, args...)
 }
 
-func (*Slogger) Info(str string) {
-	slog.Info(str)
+func (s *Slogger) Error(msg string, args ...any) {
+	s.log.Error(msg, args...)
 }
 
-func (*Slogger) Error(str string) {
-	slog.Error(str)
+func (s *Slogger) With(args ...any) domain.Logger {
+	return &Slogger{
+		log: s.log.With(args...),
+	}
 }
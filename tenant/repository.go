@@ -0,0 +1,203 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+func (r *Registry) CreateTodo(ctx context.Context, newTodo *domain.NewTodo) (*domain.Todo, error) {
+	var todo *domain.Todo
+	err := r.create(ctx, creates(1), func(repo domain.TodoRepository) (err error) {
+		todo, err = repo.CreateTodo(ctx, newTodo)
+		return err
+	})
+
+	return todo, err
+}
+
+func (r *Registry) CreateTodos(ctx context.Context, newTodos *[]domain.NewTodo) (*[]domain.Todo, error) {
+	count := 0
+	if newTodos != nil {
+		count = len(*newTodos)
+	}
+
+	var todos *[]domain.Todo
+	err := r.create(ctx, creates(count), func(repo domain.TodoRepository) (err error) {
+		todos, err = repo.CreateTodos(ctx, newTodos)
+		return err
+	})
+
+	return todos, err
+}
+
+func (r *Registry) SaveTodos(ctx context.Context, writes *[]domain.TodoWrite) (*[]domain.Todo, error) {
+	// writes create a todo unless they can reach the one they update
+	count := func(repo domain.TodoRepository) (int, error) {
+		count := 0
+		if writes == nil {
+			return count, nil
+		}
+
+		for _, write := range *writes {
+			if write.Id == "" {
+				count++
+				continue
+			}
+
+			_, err := repo.GetTodo(ctx, write.Id)
+			if errors.Is(err, domain.ErrTodoNotFound) {
+				count++
+			} else if err != nil {
+				return 0, err
+			}
+		}
+		return count, nil
+	}
+
+	var todos *[]domain.Todo
+	err := r.create(ctx, count, func(repo domain.TodoRepository) (err error) {
+		todos, err = repo.SaveTodos(ctx, writes)
+		return err
+	})
+
+	return todos, err
+}
+
+// creates counts a fixed number of todos against the quota
+func creates(count int) func(repo domain.TodoRepository) (int, error) {
+	return func(domain.TodoRepository) (int, error) {
+		return count, nil
+	}
+}
+
+// create runs fn if the namespace on ctx has room for the todos count returns,
+// holding the namespace lock so concurrent creates cannot overrun the quota
+func (r *Registry) create(ctx context.Context, count func(repo domain.TodoRepository) (int, error), fn func(repo domain.TodoRepository) error) error {
+	ns, err := r.namespace(domain.NamespaceFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	repo, err := ns.open(r.factory)
+	if err != nil {
+		return err
+	}
+
+	if ns.maxTodos > 0 {
+		n, err := count(repo)
+		if err != nil {
+			return err
+		}
+
+		// count the todos of every owner, the quota is for the whole namespace
+		stored, err := repo.CountTodos(domain.ContextWithPrincipal(ctx, domain.SystemPrincipal))
+		if err != nil {
+			return err
+		}
+		if stored.Total+n > ns.maxTodos {
+			return ErrQuotaExceeded
+		}
+	}
+
+	return fn(repo)
+}
+
+func (r *Registry) GetTodo(ctx context.Context, id string) (*domain.Todo, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.GetTodo(ctx, id)
+}
+
+func (r *Registry) GetTodos(ctx context.Context) (*[]domain.Todo, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.GetTodos(ctx)
+}
+
+func (r *Registry) CountTodos(ctx context.Context) (*domain.TodoCount, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.CountTodos(ctx)
+}
+
+func (r *Registry) GetTodosPage(ctx context.Context, after *domain.TodoCursor, limit int) (*[]domain.Todo, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.GetTodosPage(ctx, after, limit)
+}
+
+func (r *Registry) UpdateTodo(ctx context.Context, id string, todo *domain.UpdateTodo) (*domain.Todo, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.UpdateTodo(ctx, id, todo)
+}
+
+func (r *Registry) DeleteTodo(ctx context.Context, id string) error {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	return repo.DeleteTodo(ctx, id)
+}
+
+func (r *Registry) UpdateTodoIfUnchanged(ctx context.Context, id string, seq uint64, todo *domain.UpdateTodo) (*domain.Todo, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.UpdateTodoIfUnchanged(ctx, id, seq, todo)
+}
+
+func (r *Registry) DeleteTodoIfUnchanged(ctx context.Context, id string, seq uint64) error {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	return repo.DeleteTodoIfUnchanged(ctx, id, seq)
+}
+
+func (r *Registry) GetChanges(ctx context.Context, since domain.SyncPosition) (*[]domain.TodoChange, domain.SyncPosition, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, domain.SyncPosition{}, err
+	}
+
+	return repo.GetChanges(ctx, since)
+}
+
+// HealthCheck checks the repository of the default namespace, which every
+// namespace shares a backend with
+func (r *Registry) HealthCheck(ctx context.Context) error {
+	repo, err := r.repository(domain.ContextWithNamespace(ctx, domain.DEFAULT_NAMESPACE))
+	if err != nil {
+		return err
+	}
+
+	if checker, ok := repo.(domain.HealthChecker); ok {
+		return checker.HealthCheck(ctx)
+	}
+	return nil
+}
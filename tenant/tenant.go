@@ -0,0 +1,322 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+)
+
+const (
+	// DEFAULT_NAME selects the default namespace wherever a name is expected
+	DEFAULT_NAME = "default"
+)
+
+var (
+	ErrNamespaceNotFound      = fmt.Errorf("namespace not found")
+	ErrNamespaceAlreadyExists = fmt.Errorf("namespace already exists")
+	ErrInvalidNamespace       = fmt.Errorf("namespace names must be 1 to 63 lowercase letters, digits or dashes, starting with a letter or digit")
+	ErrDefaultNamespace       = fmt.Errorf("the default namespace cannot be created or deleted")
+	ErrNamespaceLimit         = fmt.Errorf("namespace limit reached")
+	ErrQuotaExceeded          = fmt.Errorf("namespace todo quota exceeded")
+	ErrInvalidQuota           = fmt.Errorf("maxTodos must not be negative")
+
+	namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+)
+
+// Factory creates the repository holding the todos of a namespace
+type Factory func(namespace string) (domain.TodoRepository, error)
+
+type Config struct {
+	// MaxNamespaces limits how many namespaces may exist besides the default one
+	MaxNamespaces int
+	// MaxTodos is the quota of namespaces created without one, zero means unlimited.
+	// The default namespace has no quota
+	MaxTodos int
+}
+
+// New returns a repository that keeps the todos of every namespace apart,
+// sending each call to the repository of the namespace on its context
+func New(factory Factory, cfg *Config) (*Registry, error) {
+	// create the default namespace up front so a broken backend fails at startup
+	repo, err := factory(domain.DEFAULT_NAMESPACE)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{
+		factory:       factory,
+		maxNamespaces: cfg.MaxNamespaces,
+		maxTodos:      cfg.MaxTodos,
+		namespaces:    make(map[string]*namespace),
+	}
+	r.namespaces[domain.DEFAULT_NAMESPACE] = &namespace{
+		name:      domain.DEFAULT_NAMESPACE,
+		createdAt: time.Now(),
+		repo:      repo,
+	}
+
+	return r, nil
+}
+
+type Registry struct {
+	factory       Factory
+	maxNamespaces int
+	maxTodos      int
+	mu            sync.RWMutex
+	namespaces    map[string]*namespace
+	// cleared is called with each namespace whose todos are dropped
+	cleared []func(namespace string)
+}
+
+type namespace struct {
+	name      string
+	maxTodos  int
+	createdAt time.Time
+	// mu serializes creating the repository and checking the quota
+	mu   sync.Mutex
+	repo domain.TodoRepository
+	// deleted turns away calls that found the namespace before it was deleted
+	deleted bool
+}
+
+// Namespace describes a namespace and its quota
+type Namespace struct {
+	Name      string
+	MaxTodos  int
+	CreatedAt time.Time
+}
+
+// Create adds an empty namespace. A maxTodos of zero uses the configured quota
+func (r *Registry) Create(name string, maxTodos int) (*Namespace, error) {
+	if resolve(name) == domain.DEFAULT_NAMESPACE {
+		return nil, ErrDefaultNamespace
+	}
+	if !namespacePattern.MatchString(name) {
+		return nil, ErrInvalidNamespace
+	}
+	if maxTodos < 0 {
+		return nil, ErrInvalidQuota
+	}
+	if maxTodos == 0 {
+		maxTodos = r.maxTodos
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.namespaces[name]; ok {
+		return nil, ErrNamespaceAlreadyExists
+	}
+	// the default namespace does not count towards the limit
+	if r.maxNamespaces > 0 && len(r.namespaces)-1 >= r.maxNamespaces {
+		return nil, ErrNamespaceLimit
+	}
+
+	ns := &namespace{
+		name:      name,
+		maxTodos:  maxTodos,
+		createdAt: time.Now(),
+	}
+	r.namespaces[name] = ns
+
+	return ns.describe(), nil
+}
+
+// OnClear registers fn to be called with the namespace whenever Reset or
+// Delete drops its todos, so state kept about them elsewhere goes with them.
+// It must be called before the registry is used
+func (r *Registry) OnClear(fn func(namespace string)) {
+	r.cleared = append(r.cleared, fn)
+}
+
+// clear must be called with the namespace lock held, so no todos are created
+// in the namespace until everyone has forgotten the dropped ones
+func (r *Registry) clear(name string) {
+	for _, fn := range r.cleared {
+		fn(name)
+	}
+}
+
+// Reset drops every todo of the namespace. Its repository is created again
+// when the namespace is next used, with a change log of its own, so sync
+// positions from before the reset expire rather than miss the dropped todos
+func (r *Registry) Reset(name string) (*Namespace, error) {
+	name = resolve(name)
+	ns, err := r.namespace(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	var repo domain.TodoRepository
+	if name == domain.DEFAULT_NAMESPACE {
+		// the default namespace is never left without a repository, so a
+		// failing backend is reported here rather than on the next request
+		repo, err = r.factory(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = closeRepository(ns.repo)
+	ns.repo = repo
+	r.clear(name)
+	return ns.describe(), err
+}
+
+func (r *Registry) Delete(name string) error {
+	name = resolve(name)
+	if name == domain.DEFAULT_NAMESPACE {
+		return ErrDefaultNamespace
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ns, ok := r.namespaces[name]
+	if !ok {
+		return ErrNamespaceNotFound
+	}
+	delete(r.namespaces, name)
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.deleted = true
+	r.clear(name)
+	return closeRepository(ns.repo)
+}
+
+// Close closes the repository of every namespace
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := make([]error, 0)
+	for _, ns := range r.namespaces {
+		ns.mu.Lock()
+		errs = append(errs, closeRepository(ns.repo))
+		ns.mu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+func closeRepository(repo domain.TodoRepository) error {
+	if closer, ok := repo.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// List returns every namespace but the default one, ordered by name
+func (r *Registry) List() []Namespace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	namespaces := make([]Namespace, 0, len(r.namespaces))
+	for name, ns := range r.namespaces {
+		if name != domain.DEFAULT_NAMESPACE {
+			namespaces = append(namespaces, *ns.describe())
+		}
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+
+	return namespaces
+}
+
+// Namespaces returns every namespace, including the default one, as it is
+// selected on a request context
+func (r *Registry) Namespaces() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.namespaces))
+	for name := range r.namespaces {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Lookup returns the namespace requests selecting name should use
+func (r *Registry) Lookup(name string) (string, error) {
+	name = resolve(name)
+	if _, err := r.namespace(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func resolve(name string) string {
+	if name == DEFAULT_NAME {
+		return domain.DEFAULT_NAMESPACE
+	}
+	return name
+}
+
+func (r *Registry) namespace(name string) (*namespace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ns, ok := r.namespaces[name]
+	if !ok {
+		return nil, ErrNamespaceNotFound
+	}
+	return ns, nil
+}
+
+// repository returns the repository of the namespace on ctx, creating it on
+// first use
+func (r *Registry) repository(ctx context.Context) (domain.TodoRepository, error) {
+	ns, err := r.namespace(domain.NamespaceFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.open(r.factory)
+}
+
+// open must be called with the namespace lock held
+func (ns *namespace) open(factory Factory) (domain.TodoRepository, error) {
+	if ns.deleted {
+		return nil, ErrNamespaceNotFound
+	}
+	if ns.repo == nil {
+		repo, err := factory(ns.name)
+		if err != nil {
+			return nil, err
+		}
+		ns.repo = repo
+	}
+
+	return ns.repo, nil
+}
+
+func (ns *namespace) describe() *Namespace {
+	name := ns.name
+	if name == domain.DEFAULT_NAMESPACE {
+		name = DEFAULT_NAME
+	}
+
+	return &Namespace{
+		Name:      name,
+		MaxTodos:  ns.maxTodos,
+		CreatedAt: ns.createdAt,
+	}
+}
@@ -0,0 +1,86 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"github.com/brendenehlers/todo-microservice/memory"
+	"github.com/brendenehlers/todo-microservice/slogger"
+)
+
+func TestChangesAfterClearing(t *testing.T) {
+	tests := []struct {
+		name  string
+		clear func(r *Registry) error
+	}{
+		{
+			name: "reset",
+			clear: func(r *Registry) error {
+				_, err := r.Reset("team")
+				return err
+			},
+		},
+		{
+			name: "delete and create again",
+			clear: func(r *Registry) error {
+				if err := r.Delete("team"); err != nil {
+					return err
+				}
+				_, err := r.Create("team", 0)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry(t)
+			if _, err := r.Create("team", 0); err != nil {
+				t.Fatal(err)
+			}
+			ctx := domain.ContextWithNamespace(context.Background(), "team")
+
+			for _, description := range []string{"first", "second"} {
+				if _, err := r.CreateTodo(ctx, &domain.NewTodo{Description: description}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, before, err := r.GetChanges(ctx, domain.SyncPosition{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.clear(r); err != nil {
+				t.Fatal(err)
+			}
+			// the new repository counts up to the old position again
+			for _, description := range []string{"third", "fourth", "fifth"} {
+				if _, err := r.CreateTodo(ctx, &domain.NewTodo{Description: description}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, _, err := r.GetChanges(ctx, before); !errors.Is(err, domain.ErrSyncExpired) {
+				t.Errorf("expected %v syncing from before the namespace was cleared, got %v", domain.ErrSyncExpired, err)
+			}
+		})
+	}
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	log, err := slogger.New("error", slogger.FORMAT_TEXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(func(string) (domain.TodoRepository, error) {
+		return memory.New(log), nil
+	}, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
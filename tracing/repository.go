@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/brendenehlers/todo-microservice/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TODO_ID_KEY = attribute.Key("todo.id")
+
+// Repository wraps repo so each of its operations is recorded as a span
+func Repository(repo domain.TodoRepository) *TracedTodoRepository {
+	return &TracedTodoRepository{
+		repo:   repo,
+		tracer: Tracer("repository"),
+	}
+}
+
+type TracedTodoRepository struct {
+	repo   domain.TodoRepository
+	tracer trace.Tracer
+}
+
+func (r *TracedTodoRepository) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return r.tracer.Start(ctx, "TodoRepository."+operation,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func (r *TracedTodoRepository) CreateTodo(ctx context.Context, newTodo *domain.NewTodo) (todo *domain.Todo, err error) {
+	ctx, span := r.start(ctx, "CreateTodo")
+	defer func() { End(span, err) }()
+
+	todo, err = r.repo.CreateTodo(ctx, newTodo)
+	if err == nil {
+		span.SetAttributes(TODO_ID_KEY.String(todo.Id))
+	}
+	return todo, err
+}
+
+func (r *TracedTodoRepository) CreateTodos(ctx context.Context, newTodos *[]domain.NewTodo) (todos *[]domain.Todo, err error) {
+	ctx, span := r.start(ctx, "CreateTodos")
+	defer func() { End(span, err) }()
+
+	if newTodos != nil {
+		span.SetAttributes(attribute.Int("todo.count", len(*newTodos)))
+	}
+	return r.repo.CreateTodos(ctx, newTodos)
+}
+
+func (r *TracedTodoRepository) SaveTodos(ctx context.Context, writes *[]domain.TodoWrite) (todos *[]domain.Todo, err error) {
+	ctx, span := r.start(ctx, "SaveTodos")
+	defer func() { End(span, err) }()
+
+	if writes != nil {
+		span.SetAttributes(attribute.Int("todo.count", len(*writes)))
+	}
+	return r.repo.SaveTodos(ctx, writes)
+}
+
+func (r *TracedTodoRepository) GetTodo(ctx context.Context, id string) (todo *domain.Todo, err error) {
+	ctx, span := r.start(ctx, "GetTodo", TODO_ID_KEY.String(id))
+	defer func() { End(span, err) }()
+
+	return r.repo.GetTodo(ctx, id)
+}
+
+func (r *TracedTodoRepository) GetTodos(ctx context.Context) (todos *[]domain.Todo, err error) {
+	ctx, span := r.start(ctx, "GetTodos")
+	defer func() { End(span, err) }()
+
+	return r.repo.GetTodos(ctx)
+}
+
+func (r *TracedTodoRepository) CountTodos(ctx context.Context) (count *domain.TodoCount, err error) {
+	ctx, span := r.start(ctx, "CountTodos")
+	defer func() { End(span, err) }()
+
+	return r.repo.CountTodos(ctx)
+}
+
+func (r *TracedTodoRepository) GetTodosPage(ctx context.Context, after *domain.TodoCursor, limit int) (todos *[]domain.Todo, err error) {
+	ctx, span := r.start(ctx, "GetTodosPage", attribute.Int("page.limit", limit))
+	defer func() { End(span, err) }()
+
+	return r.repo.GetTodosPage(ctx, after, limit)
+}
+
+func (r *TracedTodoRepository) UpdateTodo(ctx context.Context, id string, update *domain.UpdateTodo) (todo *domain.Todo, err error) {
+	ctx, span := r.start(ctx, "UpdateTodo", TODO_ID_KEY.String(id))
+	defer func() { End(span, err) }()
+
+	return r.repo.UpdateTodo(ctx, id, update)
+}
+
+func (r *TracedTodoRepository) DeleteTodo(ctx context.Context, id string) (err error) {
+	ctx, span := r.start(ctx, "DeleteTodo", TODO_ID_KEY.String(id))
+	defer func() { End(span, err) }()
+
+	return r.repo.DeleteTodo(ctx, id)
+}
+
+func (r *TracedTodoRepository) UpdateTodoIfUnchanged(ctx context.Context, id string, seq uint64, update *domain.UpdateTodo) (todo *domain.Todo, err error) {
+	ctx, span := r.start(ctx, "UpdateTodoIfUnchanged", TODO_ID_KEY.String(id), attribute.Int64("todo.seq", int64(seq)))
+	defer func() { End(span, err) }()
+
+	return r.repo.UpdateTodoIfUnchanged(ctx, id, seq, update)
+}
+
+func (r *TracedTodoRepository) DeleteTodoIfUnchanged(ctx context.Context, id string, seq uint64) (err error) {
+	ctx, span := r.start(ctx, "DeleteTodoIfUnchanged", TODO_ID_KEY.String(id), attribute.Int64("todo.seq", int64(seq)))
+	defer func() { End(span, err) }()
+
+	return r.repo.DeleteTodoIfUnchanged(ctx, id, seq)
+}
+
+func (r *TracedTodoRepository) GetChanges(ctx context.Context, since domain.SyncPosition) (changes *[]domain.TodoChange, latest domain.SyncPosition, err error) {
+	ctx, span := r.start(ctx, "GetChanges", attribute.Int64("sync.since", int64(since.Seq)))
+	defer func() { End(span, err) }()
+
+	return r.repo.GetChanges(ctx, since)
+}
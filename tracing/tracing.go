@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EXPORTER_NONE   = "none"
+	EXPORTER_OTLP   = "otlp"
+	EXPORTER_STDOUT = "stdout"
+
+	SERVICE_NAME    = "todo-microservice"
+	INSTRUMENTATION = "github.com/brendenehlers/todo-microservice"
+)
+
+var (
+	ErrInvalidExporter = fmt.Errorf("invalid trace exporter")
+)
+
+type TracingConfig struct {
+	Exporter string
+	// Endpoint is the host:port of the OTLP/HTTP collector, the exporter's
+	// default or OTEL_EXPORTER_OTLP_ENDPOINT is used when empty
+	Endpoint string
+	Insecure bool
+	// SampleRatio is the fraction of new traces recorded, sampled parents are always followed
+	SampleRatio float64
+}
+
+// New installs the global tracer provider and the W3C trace context
+// propagator. The returned function flushes any buffered spans and must be
+// called before the process exits
+func New(ctx context.Context, config *TracingConfig) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case EXPORTER_NONE, "":
+		return func(ctx context.Context) error { return nil }, nil
+	case EXPORTER_OTLP:
+		opts := make([]otlptracehttp.Option, 0)
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case EXPORTER_STDOUT:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("%w %q", ErrInvalidExporter, config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(SERVICE_NAME),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer for a package of this service. It reads the global
+// provider on every call, so it is safe to use before New has run
+func Tracer(pkg string) trace.Tracer {
+	return otel.Tracer(INSTRUMENTATION + "/" + pkg)
+}
+
+// End records err on span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}